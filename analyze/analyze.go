@@ -0,0 +1,246 @@
+// Package analyze computes the statistics of an image returned by the
+// Analyze RPC of the Transform service.
+package analyze
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"nichowil/grpc-tutorial/filter"
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// DefaultColors is the number of dominant colours found when none is asked.
+const DefaultColors = 5
+
+// MaxColors bounds the number of dominant colours that can be asked for.
+const MaxColors = 32
+
+// channel accumulates the statistics of one channel.
+type channel struct {
+	histogram [256]int64
+	min, max  float64
+	sum, sq   float64
+}
+
+func (c *channel) add(v float64, n int64) {
+	if n == 0 {
+		c.min, c.max = v, v
+	}
+	c.min = math.Min(c.min, v)
+	c.max = math.Max(c.max, v)
+	c.sum += v
+	c.sq += v * v
+	c.histogram[toByte(v)]++
+}
+
+func (c *channel) proto(name string, n int64) *pb.ChannelStats {
+	res := &pb.ChannelStats{Name: name, Histogram: append([]int64(nil), c.histogram[:]...)}
+	if n == 0 {
+		return res
+	}
+	res.Min, res.Max = c.min, c.max
+	res.Mean = c.sum / float64(n)
+	// clamped, rounding may make the variance of a flat image negative
+	res.Stddev = math.Sqrt(math.Max(c.sq/float64(n)-res.Mean*res.Mean, 0))
+	return res
+}
+
+// bins quantises colours to 5 bits per channel for clustering.
+const bins = 32 * 32 * 32
+
+// bin sums the visible pixels falling into one quantised colour.
+type bin struct {
+	n       int64
+	r, g, b float64
+}
+
+// Analyzer accumulates the statistics of the pixels of an image, in any
+// order.
+type Analyzer struct {
+	n        int64
+	channels [4]channel
+	luma     channel
+	bins     []bin
+}
+
+// New returns an Analyzer that has seen no pixel.
+func New() *Analyzer {
+	return &Analyzer{bins: make([]bin, bins)}
+}
+
+// Add counts a pixel with channels in the 0-255 range.
+func (a *Analyzer) Add(c filter.Color) {
+	for i, v := range [4]float32{c.R, c.G, c.B, c.A} {
+		a.channels[i].add(clamp(float64(v)), a.n)
+	}
+	r, g, b := clamp(float64(c.R)), clamp(float64(c.G)), clamp(float64(c.B))
+	a.luma.add(0.299*r+0.587*g+0.114*b, a.n)
+	a.n++
+
+	if c.A == 0 {
+		return
+	}
+	bn := &a.bins[int(toByte(r))>>3<<10|int(toByte(g))>>3<<5|int(toByte(b))>>3]
+	bn.n++
+	bn.r += r
+	bn.g += g
+	bn.b += b
+}
+
+// AddRGBA counts every pixel of pix, packed 8-bit RGBA as carried by
+// pb.Tile.
+func (a *Analyzer) AddRGBA(pix []byte) {
+	for i := 0; i+4 <= len(pix); i += 4 {
+		a.Add(filter.Color{R: float32(pix[i]), G: float32(pix[i+1]), B: float32(pix[i+2]), A: float32(pix[i+3])})
+	}
+}
+
+// AddFrame counts every pixel of f.
+func (a *Analyzer) AddFrame(f *filter.Frame) {
+	for _, c := range f.Pix {
+		a.Add(c)
+	}
+}
+
+// Result returns the statistics of the pixels seen so far with up to k
+// dominant colours, DefaultColors when k is 0.
+func (a *Analyzer) Result(k int) (*pb.Analysis, error) {
+	if k == 0 {
+		k = DefaultColors
+	}
+	if k < 0 || k > MaxColors {
+		return nil, fmt.Errorf("colors must be between 1 and %d, got %d", MaxColors, k)
+	}
+	res := &pb.Analysis{
+		Pixels:    a.n,
+		Luminance: a.luma.proto("luminance", a.n),
+		Dominant:  a.dominant(k),
+	}
+	for i, name := range []string{"red", "green", "blue", "alpha"} {
+		res.Channels = append(res.Channels, a.channels[i].proto(name, a.n))
+	}
+	return res, nil
+}
+
+// dominant clusters the pixels that are not fully transparent into k colours with k-means over the
+// quantised colours, weighted by their number of pixels. The centres are
+// seeded as in k-means++, but always picking the farthest colour so the
+// result does not depend on chance.
+func (a *Analyzer) dominant(k int) []*pb.DominantColor {
+	type point struct {
+		n       float64
+		r, g, b float64
+	}
+	var (
+		points []point
+		total  float64
+	)
+	for _, bn := range a.bins {
+		if bn.n > 0 {
+			n := float64(bn.n)
+			points = append(points, point{n, bn.r / n, bn.g / n, bn.b / n})
+			total += n
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	if k > len(points) {
+		k = len(points)
+	}
+	dist := func(p, q point) float64 {
+		dr, dg, db := p.r-q.r, p.g-q.g, p.b-q.b
+		return dr*dr + dg*dg + db*db
+	}
+
+	centers := make([]point, 0, k)
+	best := points[0]
+	for _, p := range points {
+		if p.n > best.n {
+			best = p
+		}
+	}
+	centers = append(centers, best)
+	nearest := make([]float64, len(points))
+	for i, p := range points {
+		nearest[i] = dist(p, best)
+	}
+	for len(centers) < k {
+		far, score := 0, -1.0
+		for i, p := range points {
+			if s := p.n * nearest[i]; s > score {
+				far, score = i, s
+			}
+		}
+		centers = append(centers, points[far])
+		for i, p := range points {
+			nearest[i] = math.Min(nearest[i], dist(p, points[far]))
+		}
+	}
+
+	assign := make([]int, len(points))
+	for iter := 0; iter < 50; iter++ {
+		changed := false
+		for i, p := range points {
+			c := 0
+			for j := range centers {
+				if dist(p, centers[j]) < dist(p, centers[c]) {
+					c = j
+				}
+			}
+			if c != assign[i] || iter == 0 {
+				assign[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		sums := make([]point, len(centers))
+		for i, p := range points {
+			s := &sums[assign[i]]
+			s.n += p.n
+			s.r += p.r * p.n
+			s.g += p.g * p.n
+			s.b += p.b * p.n
+		}
+		for j, s := range sums {
+			if s.n > 0 {
+				centers[j] = point{s.n, s.r / s.n, s.g / s.n, s.b / s.n}
+			}
+		}
+	}
+
+	shares := make([]float64, len(centers))
+	for i, p := range points {
+		shares[assign[i]] += p.n
+	}
+	var res []*pb.DominantColor
+	for j, c := range centers {
+		if shares[j] == 0 {
+			continue
+		}
+		res = append(res, &pb.DominantColor{
+			Color: filter.Color{R: float32(c.r), G: float32(c.g), B: float32(c.b), A: 255}.Proto(),
+			Share: shares[j] / total,
+		})
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Share > res[j].Share })
+	return res
+}
+
+func clamp(v float64) float64 {
+	if v < 0 || v != v {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+func toByte(v float64) uint8 {
+	return uint8(clamp(v) + 0.5)
+}
@@ -0,0 +1,204 @@
+// Package cache keeps transformed images by a digest of their input and of
+// the operation applied to it, in memory and optionally on disk, dropping
+// the least recently used ones beyond a size bound.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// MetadataDigest is the metadata key of a Transform stream a client sets to
+// the Digest of the pixels it sends, letting the server find the result of
+// an image it transformed before. Streams without it are not cached.
+const MetadataDigest = "image-digest"
+
+// TrailerStatus is the trailer key reporting whether a result came from the
+// cache, "hit" or "miss".
+const TrailerStatus = "cache"
+
+// Digest returns the hex encoded SHA-256 of pix, the packed 8-bit RGBA
+// pixels of an image row by row.
+func Digest(pix []byte) string {
+	sum := sha256.Sum256(pix)
+	return hex.EncodeToString(sum[:])
+}
+
+// Key returns a key naming every part, in order.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	var n [8]byte
+	for _, p := range parts {
+		// length prefixed so that no two lists of parts hash alike
+		binary.BigEndian.PutUint64(n[:], uint64(len(p)))
+		h.Write(n[:])
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lru tracks the size of entries in the order they were last used.
+type lru struct {
+	max, size int64
+	ll        *list.List
+	items     map[string]*list.Element
+	// evicted is called with the key of every entry dropped to stay within
+	// max.
+	evicted func(key string)
+}
+
+type entry struct {
+	key   string
+	value []byte // nil on disk
+	size  int64
+}
+
+func newLRU(max int64, evicted func(string)) *lru {
+	return &lru{max: max, ll: list.New(), items: make(map[string]*list.Element), evicted: evicted}
+}
+
+func (l *lru) get(key string) (*entry, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return el.Value.(*entry), true
+}
+
+// add inserts e as the most recently used entry. Entries larger than max
+// are not kept.
+func (l *lru) add(e *entry) bool {
+	if e.size > l.max {
+		return false
+	}
+	if el, ok := l.items[e.key]; ok {
+		l.size -= el.Value.(*entry).size
+		l.ll.Remove(el)
+	}
+	l.items[e.key] = l.ll.PushFront(e)
+	l.size += e.size
+	for l.size > l.max {
+		old := l.ll.Remove(l.ll.Back()).(*entry)
+		delete(l.items, old.key)
+		l.size -= old.size
+		if l.evicted != nil {
+			l.evicted(old.key)
+		}
+	}
+	return true
+}
+
+// Cache holds values by key in memory, and on disk when it has a
+// directory. Values read from disk move back to memory. It is safe for
+// concurrent use.
+type Cache struct {
+	mu     sync.Mutex
+	memory *lru
+	disk   *lru // nil without a directory
+	dir    string
+}
+
+// New returns a cache holding up to memorySize bytes in memory. When dir is
+// not empty up to diskSize bytes are kept in files of dir as well, including
+// those left by a previous cache using dir.
+func New(memorySize int64, dir string, diskSize int64) (*Cache, error) {
+	c := &Cache{memory: newLRU(memorySize, nil), dir: dir}
+	if dir == "" {
+		return c, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	c.disk = newLRU(diskSize, func(key string) {
+		if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+			log.Printf("cache: %v", err)
+		}
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// oldest first, so the most recently written are the most recently used
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		if f.Mode().IsRegular() && len(f.Name()) == sha256.Size*2 {
+			c.disk.add(&entry{key: f.Name(), size: f.Size()})
+		}
+	}
+	return c, nil
+}
+
+// Get returns the value of key.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.memory.get(key); ok {
+		return e.value, true
+	}
+	if c.disk == nil {
+		return nil, false
+	}
+	if _, ok := c.disk.get(key); !ok {
+		return nil, false
+	}
+	value, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		log.Printf("cache: %v", err)
+		return nil, false
+	}
+	c.memory.add(&entry{key: key, value: value, size: int64(len(value))})
+	return value, true
+}
+
+// Put stores value under key. value must not be modified afterwards.
+func (c *Cache) Put(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.memory.add(&entry{key: key, value: value, size: int64(len(value))})
+	if c.disk == nil {
+		return
+	}
+	if _, ok := c.disk.get(key); ok {
+		return
+	}
+	if err := c.write(key, value); err != nil {
+		log.Printf("cache: %v", err)
+		return
+	}
+	if !c.disk.add(&entry{key: key, size: int64(len(value))}) {
+		os.Remove(c.path(key))
+	}
+}
+
+// write stores value in the file of key, never leaving a partial file
+// behind.
+func (c *Cache) write(key string, value []byte) error {
+	f, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key)
+}
@@ -0,0 +1,150 @@
+// Package codec decodes and encodes the image file formats understood by the
+// Transform service.
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	// Registers the WebP decoder, WebP files can be read but not written.
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the size of an image Decode accepts, so a small file
+// cannot ask for an arbitrary amount of memory.
+const MaxPixels = 1 << 26
+
+// DefaultQuality is the JPEG quality used when Options do not set one.
+const DefaultQuality = jpeg.DefaultQuality
+
+// Options tune how an image is encoded.
+type Options struct {
+	// Quality of a JPEG image, from 1 to 100.
+	Quality int
+}
+
+var encoders = map[string]func(w io.Writer, img image.Image, o Options) error{
+	"jpeg": func(w io.Writer, img image.Image, o Options) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: o.Quality})
+	},
+	"png": func(w io.Writer, img image.Image, o Options) error { return png.Encode(w, img) },
+	"gif": func(w io.Writer, img image.Image, o Options) error { return gif.Encode(w, paletted(img), nil) },
+	"bmp": func(w io.Writer, img image.Image, o Options) error { return bmp.Encode(w, img) },
+	"tiff": func(w io.Writer, img image.Image, o Options) error {
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	},
+}
+
+// decodeOnly lists the formats that are read but never written.
+var decodeOnly = map[string]bool{"webp": true}
+
+// Formats returns the names of the formats Encode supports in sorted order.
+func Formats() []string {
+	res := make([]string, 0, len(encoders))
+	for name := range encoders {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Normalize returns the canonical name of format, e.g. jpeg for "JPG".
+func Normalize(format string) string {
+	format = strings.ToLower(strings.TrimPrefix(format, "."))
+	switch format {
+	case "jpg":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	}
+	return format
+}
+
+// FormatFromPath returns the format matching the extension of path.
+func FormatFromPath(path string) (string, error) {
+	format := Normalize(filepath.Ext(path))
+	if _, ok := encoders[format]; !ok {
+		return "", fmt.Errorf("cannot tell the image format of %q from its extension: %v", path, unknownFormat(format))
+	}
+	return format, nil
+}
+
+// Decode decodes an image file. When format is not empty the file must be in
+// that format. It returns the name of the format that was decoded.
+func Decode(data []byte, format string) (image.Image, string, error) {
+	format = Normalize(format)
+	if _, ok := encoders[format]; format != "" && !ok && !decodeOnly[format] {
+		return nil, "", unknownFormat(format)
+	}
+
+	cfg, found, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if format != "" && found != format {
+		return nil, "", fmt.Errorf("image is %s, not %s", found, format)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, "", fmt.Errorf("image of %dx%d pixels is larger than the %d pixels allowed", cfg.Width, cfg.Height, MaxPixels)
+	}
+
+	img, found, err := image.Decode(bytes.NewReader(data))
+	return img, found, err
+}
+
+// Encode writes img to w in format. Transparency is kept by every format but
+// JPEG. o may be nil.
+func Encode(w io.Writer, img image.Image, format string, o *Options) error {
+	encode, ok := encoders[Normalize(format)]
+	if !ok {
+		return unknownFormat(format)
+	}
+	opts := Options{Quality: DefaultQuality}
+	if o != nil && o.Quality != 0 {
+		opts.Quality = o.Quality
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, got %d", opts.Quality)
+	}
+	return encode(w, img, opts)
+}
+
+// paletted reduces img to the Plan 9 palette, reserving one entry for
+// transparent pixels when img has any.
+func paletted(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		res := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(res, bounds, img, bounds.Min)
+		return res
+	}
+
+	pal := append(color.Palette{color.Transparent}, palette.Plan9[:255]...)
+	res := image.NewPaletted(bounds, pal)
+	draw.FloydSteinberg.Draw(res, bounds, img, bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0x8000 {
+				res.SetColorIndex(x, y, 0)
+			}
+		}
+	}
+	return res
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown image format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+}
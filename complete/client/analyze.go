@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// analyzeImage sends the image file at path to the Analyze RPC, as it is
+// when encoded is set and decoded into tiles otherwise.
+func analyzeImage(ctx context.Context, client pb.TransformClient, path string, encoded bool, colors int) (*pb.Analysis, error) {
+	stream, err := client.Analyze(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqs []*pb.AnalyzeRequest
+	if encoded {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for off := 0; off == 0 || off < len(data); off += chunkSize {
+			end := off + chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			reqs = append(reqs, &pb.AnalyzeRequest{Input: &pb.AnalyzeRequest_Chunk{Chunk: &pb.ImageChunk{Data: data[off:end]}}})
+		}
+	} else {
+		img, err := getImageFromFilePath(path)
+		if err != nil {
+			return nil, err
+		}
+		src := imageToNRGBA(img)
+		for _, r := range tileRects(src.Bounds(), *tileWidth, *tileHeight) {
+			reqs = append(reqs, &pb.AnalyzeRequest{Input: &pb.AnalyzeRequest_Tile{Tile: cutTile(src, r)}})
+		}
+	}
+	reqs[0].Colors = int32(colors)
+
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			// the error of the stream is returned by CloseAndRecv
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// sparkBlocks draw a histogram on a single line, from empty to full.
+var sparkBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// sparkline draws histogram on a single line of width characters.
+func sparkline(histogram []int64, width int) string {
+	if len(histogram) == 0 {
+		return ""
+	}
+	per := (len(histogram) + width - 1) / width
+	var (
+		sums []int64
+		max  int64
+	)
+	for i := 0; i < len(histogram); i += per {
+		var sum int64
+		for j := i; j < i+per && j < len(histogram); j++ {
+			sum += histogram[j]
+		}
+		if sum > max {
+			max = sum
+		}
+		sums = append(sums, sum)
+	}
+
+	var b strings.Builder
+	for _, sum := range sums {
+		i := 0
+		if max > 0 {
+			i = int((sum*int64(len(sparkBlocks)-1) + max - 1) / max)
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// printReport writes a readable report of a to w.
+func printReport(w io.Writer, a *pb.Analysis) {
+	if a.Width > 0 {
+		fmt.Fprintf(w, "Image: %dx%d, %d pixels\n\n", a.Width, a.Height, a.Pixels)
+	} else {
+		fmt.Fprintf(w, "Image: %d pixels\n\n", a.Pixels)
+	}
+
+	fmt.Fprintf(w, "%-10s %7s %7s %7s %7s  %s\n", "channel", "min", "max", "mean", "stddev", "histogram")
+	for _, c := range append(a.Channels, a.Luminance) {
+		fmt.Fprintf(w, "%-10s %7.1f %7.1f %7.1f %7.1f  %s\n", c.Name, c.Min, c.Max, c.Mean, c.Stddev, sparkline(c.Histogram, 32))
+	}
+
+	if len(a.Dominant) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nDominant colours:\n")
+	for _, d := range a.Dominant {
+		c := d.Color
+		fmt.Fprintf(w, "  #%02x%02x%02x  %5.1f%%\n", uint8(c.R+0.5), uint8(c.G+0.5), uint8(c.B+0.5), d.Share*100)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"nichowil/grpc-tutorial/codec"
+	"nichowil/grpc-tutorial/progress"
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+var (
+	include     = flag.String("include", "*.jpg,*.jpeg,*.png,*.gif,*.bmp,*.tif,*.tiff,*.webp", "Comma separated globs of the files a batch transforms, matched against their name, or their path within the directory when they hold a /, ignoring case")
+	exclude     = flag.String("exclude", "", "Comma separated globs of the files a batch skips, matched like -include")
+	concurrency = flag.Int("concurrency", 4, "Number of images of a batch transformed at the same time")
+	force       = flag.Bool("force", false, "Transform the images of a batch again even when their result is up to date")
+)
+
+// batchItem is the outcome of one image of a batch.
+type batchItem struct {
+	Path string `json:"path"`
+	Out  string `json:"out,omitempty"`
+	// Status is done, skipped or failed.
+	Status string `json:"status"`
+	Code   string `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchSummary counts the outcomes of a batch, and its failures by code.
+type batchSummary struct {
+	Done    int            `json:"done"`
+	Skipped int            `json:"skipped"`
+	Failed  int            `json:"failed"`
+	Codes   map[string]int `json:"codes,omitempty"`
+}
+
+// runBatch transforms the images found in the directory args[0] into the
+// same tree under the directory args[1], -concurrency at a time. Images
+// whose result is newer than them are skipped, images whose result would
+// be the result of another one fail. It fails when any image failed.
+func runBatch(ctx context.Context, client pb.TransformClient, args []string, steps *pb.Pipeline, format string) error {
+	if len(args) != 2 {
+		return errors.New("batch needs the directory of the images and the directory of the results")
+	}
+	in, out := args[0], args[1]
+	paths, err := findImages(in, out, splitList(*include), splitList(*exclude))
+	if err != nil {
+		return err
+	}
+	clashes := findClashes(in, paths, format)
+
+	bar := newProgress("batch", "images", len(paths))
+	// images are reported as a whole, not each one
+	progressMode = progress.Quiet
+
+	n := *concurrency
+	if n < 1 {
+		n = 1
+	}
+	todo := make(chan string)
+	results := make(chan batchItem)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range todo {
+				if others := clashes[rel]; len(others) > 0 {
+					results <- clashItem(in, out, rel, format, others)
+					continue
+				}
+				results <- batchImage(ctx, client, in, out, rel, steps, format)
+			}
+		}()
+	}
+	go func() {
+		for _, rel := range paths {
+			todo <- rel
+		}
+		close(todo)
+		wg.Wait()
+		close(results)
+	}()
+
+	sum := batchSummary{Codes: make(map[string]int)}
+	count := 0
+	for item := range results {
+		switch item.Status {
+		case "done":
+			sum.Done++
+		case "skipped":
+			sum.Skipped++
+		default:
+			sum.Failed++
+			sum.Codes[item.Code]++
+		}
+		count++
+		bar.Set(int64(count))
+		item := item
+		if err := emit(item, func(w io.Writer) {
+			switch item.Status {
+			case "failed":
+				fmt.Fprintf(w, "failed  %s: %s %s\n", item.Path, item.Code, item.Error)
+			default:
+				fmt.Fprintf(w, "%-7s %s -> %s\n", item.Status, item.Path, item.Out)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	bar.Finish()
+
+	if err := emit(sum, func(w io.Writer) {
+		fmt.Fprintf(w, "%d done, %d skipped, %d failed\n", sum.Done, sum.Skipped, sum.Failed)
+		codes := make([]string, 0, len(sum.Codes))
+		for code := range sum.Codes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "  %-18s %d\n", code, sum.Codes[code])
+		}
+	}); err != nil {
+		return err
+	}
+	if sum.Failed > 0 {
+		return fmt.Errorf("%d of %d images failed", sum.Failed, len(paths))
+	}
+	return nil
+}
+
+// batchImage transforms the image at rel within in into the same path
+// within out, unless its result is up to date.
+func batchImage(ctx context.Context, client pb.TransformClient, in, out, rel string, steps *pb.Pipeline, format string) batchItem {
+	src := filepath.Join(in, rel)
+	format = batchFormat(src, format)
+	dst := filepath.Join(out, resultPath(rel, format))
+	item := batchItem{Path: src, Out: dst, Status: "done"}
+	fail := func(err error) batchItem {
+		item.Status, item.Code, item.Error = "failed", status.Code(err).String(), status.Convert(err).Message()
+		return item
+	}
+
+	if !*force && upToDate(src, dst) {
+		item.Status = "skipped"
+		return item
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fail(err)
+	}
+	// an interrupted batch leaves no result that looks up to date
+	part := dst + ".part"
+	if _, err := transformImage(ctx, client, src, part, steps, format); err != nil {
+		os.Remove(part)
+		return fail(err)
+	}
+	if err := os.Rename(part, dst); err != nil {
+		os.Remove(part)
+		return fail(err)
+	}
+	return item
+}
+
+// clashItem is the failure of the image at rel within in, whose result
+// would be the result of others too.
+func clashItem(in, out, rel, format string, others []string) batchItem {
+	src := filepath.Join(in, rel)
+	return batchItem{
+		Path:   src,
+		Out:    filepath.Join(out, resultPath(rel, batchFormat(src, format))),
+		Status: "failed",
+		Code:   codes.AlreadyExists.String(),
+		Error:  "the result would also be the result of " + strings.Join(others, ", "),
+	}
+}
+
+// batchFormat returns the format of the result of the image at src, format
+// unless it is empty.
+func batchFormat(src, format string) string {
+	if format != "" {
+		return format
+	}
+	format, err := codec.FormatFromPath(src)
+	if err != nil {
+		// such as WebP, which is only read
+		return "png"
+	}
+	return format
+}
+
+// findClashes returns the images of paths, relative to in, whose result
+// would be the result of other images too, such as a.jpg and a.png with
+// -format png, with the paths of those other images.
+func findClashes(in string, paths []string, format string) map[string][]string {
+	byResult := make(map[string][]string)
+	for _, rel := range paths {
+		res := resultPath(rel, batchFormat(filepath.Join(in, rel), format))
+		byResult[res] = append(byResult[res], rel)
+	}
+	clashes := make(map[string][]string)
+	for _, rels := range byResult {
+		if len(rels) < 2 {
+			continue
+		}
+		for _, rel := range rels {
+			for _, other := range rels {
+				if other != rel {
+					clashes[rel] = append(clashes[rel], filepath.Join(in, other))
+				}
+			}
+		}
+	}
+	return clashes
+}
+
+// findImages returns the paths, relative to dir, of the files in dir that
+// match include and not exclude, in lexical order. The directory out is
+// skipped when it is within dir.
+func findImages(dir, out string, include, exclude []string) ([]string, error) {
+	outAbs, err := filepath.Abs(out)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil && abs == outAbs {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if matchAny(include, rel) && !matchAny(exclude, rel) {
+			res = append(res, rel)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// matchAny reports whether one of patterns matches rel, see -include.
+func matchAny(patterns []string, rel string) bool {
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		name := rel
+		if !strings.Contains(p, "/") {
+			name = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resultPath returns rel with the extension of format, unless its extension
+// already names it.
+func resultPath(rel, format string) string {
+	ext := filepath.Ext(rel)
+	if codec.Normalize(ext) == format {
+		return rel
+	}
+	if format == "jpeg" {
+		format = "jpg"
+	}
+	return strings.TrimSuffix(rel, ext) + "." + format
+}
+
+// upToDate reports whether dst exists and is not older than src.
+func upToDate(src, dst string) bool {
+	s, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	d, err := os.Stat(dst)
+	return err == nil && !d.ModTime().Before(s.ModTime())
+}
+
+func splitList(s string) (res []string) {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -3,30 +3,73 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"image"
-	"image/color"
-	"image/jpeg"
-	_ "image/png"
+	"image/draw"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"nichowil/grpc-tutorial/cache"
+	"nichowil/grpc-tutorial/codec"
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/integrity"
+	"nichowil/grpc-tutorial/progress"
+	"nichowil/grpc-tutorial/transfer"
 	pb "nichowil/grpc-tutorial/transform"
 )
 
 var (
 	imagePath  = flag.String("img", "images/test.jpg", "Image filepath")
 	serverAddr = flag.String("addr", "localhost:50051", "The server address in the format of host:port")
+	tileWidth  = flag.Int("tile-width", 0, "Width of each tile sent to the server, 0 sends whole rows")
+	tileHeight = flag.Int("tile-height", 16, "Height of each tile sent to the server")
+	filterName = flag.String("filter", filter.DefaultName, "Filter applied by the server, one of "+strings.Join(filter.Names(), ", "))
+	params     = flag.String("params", "", "Filter parameters as comma separated key=value pairs")
+	kernel     = flag.String("kernel", "", "Comma separated weights of the kernel filter, row by row")
+	edge       = flag.String("edge", "clamp", "How filters reading neighbouring pixels treat the image border: clamp, wrap or mirror")
+	pipeline   = flag.String("pipeline", "", `Filters applied one after the other, replacing -filter, e.g. "grayscale|sharpen:amount=2"`)
+	encoded    = flag.Bool("encoded", false, "Send the image file as it is and let the server decode it")
+	outPath    = flag.String("out", "images/result.jpg", "Filepath of the transformed image")
+	outFormat  = flag.String("format", "", "Format of the transformed image, one of "+strings.Join(codec.Formats(), ", ")+", taken from the extension of -out when empty")
+	quality    = flag.Int("quality", codec.DefaultQuality, "Quality of a JPEG image, from 1 to 100")
+	region     = flag.String("region", "", `Rectangle the filters are limited to, as "x,y,width,height"`)
+	ordered    = flag.Bool("ordered", false, "Ask the server to send the transformed tiles back in the order they were sent")
+	window     = flag.Int("window", transfer.DefaultWindow, "Number of tiles prepared ahead of the stream")
+	verify     = flag.Bool("verify", true, "Check that every pixel of the image and of the result went through the stream unchanged")
+	resume     = flag.Bool("resume", false, "Send the image over a transfer resumed after the connection drops")
+	retries    = flag.Int("retries", transfer.DefaultRetries, "Number of times a -resume transfer is resumed in a row without progress")
+	wait       = flag.Bool("wait", false, "Make job submit wait for the job to end and fetch its result")
+	colors     = flag.Int("colors", 5, "Number of dominant colours reported by the analyze command")
+	timeout    = flag.Duration("timeout", 0, "Deadline of the command, none when 0")
+	progressTo = flag.String("progress", "bar", "How progress is reported on stderr: bar, json or quiet")
+	serverProg = flag.Duration("server-progress", 500*time.Millisecond, "How often the server reports its progress during a transform, 0 never")
+	maskPath   = flag.String("mask", "", "Image file whose brightness sets how much of the filtered image shows in each pixel of -region, or of the image from its top-left corner when -region is empty")
 )
 
-type imageVector struct {
-	r float32
-	g float32
-	b float32
-	a float32
+const (
+	// chunkSize is the size of the chunks of an uploaded file.
+	chunkSize = 1 << 20
+	// maxResponseSize bounds the size of a transformed image file.
+	maxResponseSize = 64 << 20
+)
+
+// progressMode is how progress is reported, parsed from -progress.
+var progressMode progress.Mode
+
+// newProgress returns a reporter of the progress of label on stderr, counted
+// in unit out of total.
+func newProgress(label, unit string, total int) *progress.Reporter {
+	return progress.New(os.Stderr, progressMode, label, unit, int64(total))
 }
 
 func getImageFromFilePath(filePath string) (image.Image, error) {
@@ -39,147 +82,497 @@ func getImageFromFilePath(filePath string) (image.Image, error) {
 	return image, err
 }
 
-func saveImageToFilePath(img image.Image, filePath string) error {
+func saveImageToFilePath(img image.Image, filePath, format string) error {
 	f, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	if err = jpeg.Encode(f, img, nil); err != nil {
+	if err = codec.Encode(f, img, format, &codec.Options{Quality: *quality}); err != nil {
 		return err
 	}
-	return nil
+	return f.Close()
 }
 
-func imageToVector(img image.Image) (res [][]imageVector) {
+// imageToNRGBA copies img into a non-premultiplied RGBA image whose bounds
+// start at (0, 0), the layout carried by pb.Tile.
+func imageToNRGBA(img image.Image) *image.NRGBA {
 	bounds := img.Bounds()
-	width, height := bounds.Max.X, bounds.Max.Y
-	res = make([][]imageVector, height)
-	for y := 0; y < height; y++ {
-		res[y] = make([]imageVector, width)
-		for x := 0; x < width; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			res[y][x] = imageVector{
-				r: float32(r >> 8),
-				g: float32(g >> 8),
-				b: float32(b >> 8),
-				a: float32(a >> 8),
-			}
+	res := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(res, res.Bounds(), img, bounds.Min, draw.Src)
+	return res
+}
+
+// cutTile packs the pixels of img within r into a tile.
+func cutTile(img *image.NRGBA, r image.Rectangle) *pb.Tile {
+	tile := &pb.Tile{
+		Origin: &pb.Point{X: int32(r.Min.X), Y: int32(r.Min.Y)},
+		Width:  int32(r.Dx()),
+		Height: int32(r.Dy()),
+		Rgba:   make([]byte, 0, r.Dx()*r.Dy()*4),
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := img.PixOffset(r.Min.X, y)
+		tile.Rgba = append(tile.Rgba, img.Pix[i:i+r.Dx()*4]...)
+	}
+	return tile
+}
+
+// pasteTile writes the pixels of tile into img, dropping anything that falls
+// outside of its bounds. Malformed tiles are ignored.
+func pasteTile(img *image.NRGBA, tile *pb.Tile) {
+	width := int(tile.Width)
+	if width <= 0 || tile.Height <= 0 || len(tile.Rgba) != width*int(tile.Height)*4 {
+		return
+	}
+	origin := image.Pt(int(tile.Origin.GetX()), int(tile.Origin.GetY()))
+	r := image.Rectangle{origin, origin.Add(image.Pt(width, int(tile.Height)))}.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		src := ((y-origin.Y)*width + (r.Min.X - origin.X)) * 4
+		copy(img.Pix[img.PixOffset(r.Min.X, y):], tile.Rgba[src:src+r.Dx()*4])
+	}
+}
+
+// tileRects splits bounds into tiles of at most w by h pixels, row by row.
+func tileRects(bounds image.Rectangle, w, h int) (res []image.Rectangle) {
+	if w <= 0 {
+		w = bounds.Dx()
+	}
+	if h <= 0 {
+		h = 1
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += h {
+		for x := bounds.Min.X; x < bounds.Max.X; x += w {
+			res = append(res, image.Rect(x, y, x+w, y+h).Intersect(bounds))
 		}
 	}
 	return
 }
 
-func vectorToImage(res [][]imageVector) (img image.Image) {
-	height := len(res)
-	width := len(res[0])
+// readRegion parses a rectangle written as "x,y,width,height" and reads the
+// mask at maskPath, if any, as the coverage of every pixel of the rectangle.
+// Without a rectangle the mask covers the image from its top-left corner.
+func readRegion(rect, maskPath string) (*pb.Region, error) {
+	res := &pb.Region{Origin: &pb.Point{}}
+	if rect != "" {
+		var v [4]int32
+		fields := strings.Split(rect, ",")
+		if len(fields) != len(v) {
+			return nil, fmt.Errorf("%q is not x,y,width,height", rect)
+		}
+		for i, f := range fields {
+			n, err := strconv.ParseInt(strings.TrimSpace(f), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not x,y,width,height: %v", rect, err)
+			}
+			v[i] = int32(n)
+		}
+		res.Origin.X, res.Origin.Y, res.Width, res.Height = v[0], v[1], v[2], v[3]
+	}
+	if maskPath == "" {
+		return res, nil
+	}
 
-	upLeft := image.Point{0, 0}
-	lowRight := image.Point{width, height}
+	img, err := getImageFromFilePath(maskPath)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	if rect == "" {
+		res.Width, res.Height = int32(bounds.Dx()), int32(bounds.Dy())
+	}
+	if bounds.Dx() != int(res.Width) || bounds.Dy() != int(res.Height) {
+		return nil, fmt.Errorf("mask of %dx%d does not match the %dx%d region", bounds.Dx(), bounds.Dy(), res.Width, res.Height)
+	}
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	res.Mask = gray.Pix
+	return res, nil
+}
 
-	tmpImg := image.NewRGBA(image.Rectangle{upLeft, lowRight})
+// regionBounds returns the part of bounds in r, all of it when r is nil or
+// has no size.
+func regionBounds(r *pb.Region, bounds image.Rectangle) image.Rectangle {
+	if r == nil || r.Width == 0 && r.Height == 0 {
+		return bounds
+	}
+	min := image.Pt(int(r.Origin.GetX()), int(r.Origin.GetY()))
+	return image.Rectangle{min, min.Add(image.Pt(int(r.Width), int(r.Height)))}.Intersect(bounds)
+}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			pixel := color.RGBA{
-				uint8(res[y][x].r),
-				uint8(res[y][x].g),
-				uint8(res[y][x].b),
-				uint8(res[y][x].a),
+// logDetails logs the fields a server rejected along with err.
+func logDetails(err error) {
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				log.Printf("%s: %s", v.GetField(), v.GetDescription())
 			}
-			tmpImg.Set(x, y, pixel)
 		}
 	}
-	img = tmpImg
-	return
+}
+
+// usage describes the commands, run by the first argument, and the flags
+// they share.
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [command] [arguments] [flags]
+
+Commands:
+  hello [name]                  greet the server
+  simulate-error [message...]   print the errors the server simulates for each message
+  transform                     transform the image at -img into -out, the default command
+  batch <dir> <out dir>         transform the images found in a directory into the same tree in another
+  watch <dir> <out dir>         transform the images of a directory as batch does, then those written to it
+                                until interrupted
+  analyze                       print statistics of the image at -img
+  job submit|status|watch|cancel|fetch [id]
+                                manage background jobs
+  health [service]              check that the server, or one of its services, is serving
+
+Flags may come before, between or after the arguments:
+`, os.Args[0])
+	flag.PrintDefaults()
 }
 
 func main() {
-	flag.Parse()
-	var (
-		newImageV [][]imageVector
-	)
+	flag.Usage = usage
+	args := parseArgs(os.Args[1:])
+	command := "transform"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "hello", "simulate-error", "transform", "batch", "watch", "analyze", "job", "health":
+	default:
+		log.Fatalf("unknown command %q, expected hello, simulate-error, transform, batch, watch, analyze, job or health", command)
+	}
 
-	image, err := getImageFromFilePath(*imagePath)
-	if err != nil {
-		log.Fatalf("fail to get image: %v", err)
+	var err error
+	if progressMode, err = progress.ParseMode(*progressTo); err != nil {
+		log.Fatalf("invalid -progress: %v", err)
+	}
+	if err := parseOutput(*outputFlag); err != nil {
+		log.Fatalf("invalid -o: %v", err)
+	}
+
+	var steps *pb.Pipeline
+	if *pipeline != "" {
+		if steps, err = filter.ParsePipeline(*pipeline); err != nil {
+			log.Fatalf("invalid pipeline: %v", err)
+		}
+	}
+	if *region != "" || *maskPath != "" {
+		r, err := readRegion(*region, *maskPath)
+		if err != nil {
+			log.Fatalf("invalid region: %v", err)
+		}
+		if steps == nil {
+			// a region is part of a pipeline, made of -filter alone here
+			if steps, err = filter.ParsePipeline(*filterName); err != nil {
+				log.Fatalf("invalid filter: %v", err)
+			}
+			steps.Steps[0].Edge = *edge
+			if steps.Steps[0].Params, err = filter.ParseParams(*params); err != nil {
+				log.Fatalf("invalid filter parameters: %v", err)
+			}
+			if steps.Steps[0].Kernel, err = filter.ParseKernel(*kernel); err != nil {
+				log.Fatalf("invalid kernel: %v", err)
+			}
+		}
+		steps.Region = r
 	}
 
-	bounds := image.Bounds()
-	width, height := bounds.Max.X, bounds.Max.Y
-	newImageV = make([][]imageVector, height)
-	for y := 0; y < height; y++ {
-		newImageV[y] = make([]imageVector, width)
+	format := codec.Normalize(*outFormat)
+	if format == "" && (command == "transform" || command == "job") {
+		if format, err = codec.FormatFromPath(*outPath); err != nil {
+			log.Fatalf("fail to pick output format: %v", err)
+		}
 	}
 
-	conn, err := grpc.Dial(*serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	tracer, err := newTracer()
+	if err != nil {
+		log.Fatalf("invalid -trace: %v", err)
+	}
+	opts, err := dialOptions(tracer)
+	if err != nil {
+		log.Fatalf("fail to dial: %v", err)
+	}
+	conn, err := grpc.Dial(*serverAddr, opts...)
 	if err != nil {
 		log.Fatalf("fail to dial: %v", err)
 	}
 	defer conn.Close()
 	client := pb.NewTransformClient(conn)
-	log.Println("dial success")
 
-	stream, err := client.Transform(context.Background())
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		filter.MetadataName, *filterName,
+		filter.MetadataParams, *params,
+		filter.MetadataKernel, *kernel,
+		filter.MetadataEdge, *edge,
+	)
+	ctx, span := startCommand(ctx, tracer, command)
+
+	switch command {
+	case "hello":
+		err = sayHello(ctx, client, args)
+	case "simulate-error":
+		err = simulateErrors(ctx, client, args)
+	case "health":
+		err = checkHealth(ctx, conn, args)
+	case "job":
+		err = runJob(ctx, client, args, steps, format)
+	case "analyze":
+		var res *pb.Analysis
+		if res, err = analyzeImage(ctx, client, *imagePath, *encoded, *colors); err == nil {
+			err = emit(res, func(w io.Writer) { printReport(w, res) })
+		}
+	case "transform":
+		err = transform(ctx, client, steps, format)
+	case "batch":
+		err = runBatch(ctx, client, args, steps, format)
+	case "watch":
+		err = runWatch(ctx, client, args, steps, format)
+	}
+	endCommand(tracer, span, err)
 	if err != nil {
-		log.Fatalf("fail to call procedure: %v", err)
+		logDetails(err)
+		log.Fatalf("%s failed: %v", command, err)
 	}
+}
 
-	waitc := make(chan struct{})
+// saved describes the transformed image written to disk.
+type saved struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+}
 
-	go func() {
-		i := 0
-		for {
-			// receive next pixel from stream
-			r, err := stream.Recv()
-			if err == io.EOF {
-				// read done.
-				close(waitc)
-				return
-			}
-			if err != nil {
-				log.Fatalf("Failed to receive a pixel : %v", err)
-			}
+// transform transforms the image at -img and saves the result to -out in
+// format.
+func transform(ctx context.Context, client pb.TransformClient, steps *pb.Pipeline, format string) error {
+	res, err := transformImage(ctx, client, *imagePath, *outPath, steps, format)
+	if err != nil {
+		return err
+	}
+	return emit(res, func(w io.Writer) {
+		fmt.Fprintf(w, "Saved %dx%d %s image to %s\n", res.Width, res.Height, res.Format, res.Path)
+	})
+}
 
-			// update pixel on image vector
-			newImageV[r.Point.Y][r.Point.X] = imageVector{
-				r: r.Color.R,
-				g: r.Color.G,
-				b: r.Color.B,
-				a: r.Color.A,
-			}
-			log.Printf("Receiving... %d/%d", i, width*height)
-			i++
-		}
-	}()
-
-	imageV := imageToVector(image)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			sendPixel := pb.Pixel{
-				Color: &pb.Color{
-					R: imageV[y][x].r,
-					G: imageV[y][x].g,
-					B: imageV[y][x].b,
-					A: imageV[y][x].a,
-				},
-				Point: &pb.Point{
-					X: int32(x),
-					Y: int32(y),
-				},
+// transformImage transforms the image file at path and saves the result to
+// out in format, as the flags ask.
+func transformImage(ctx context.Context, client pb.TransformClient, path, out string, steps *pb.Pipeline, format string) (*saved, error) {
+	res := &saved{Path: out, Format: format}
+	if *encoded {
+		r, err := transformFile(ctx, client, path, steps, out, format)
+		if err != nil {
+			return nil, err
+		}
+		res.Width, res.Height, res.Format = int(r.Width), int(r.Height), r.Format
+		return res, nil
+	}
+	img, err := getImageFromFilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var dst *image.NRGBA
+	if *resume {
+		dst, err = transformResumable(ctx, client, imageToNRGBA(img), steps)
+	} else {
+		dst, err = transformTiles(ctx, client, imageToNRGBA(img), steps)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := saveImageToFilePath(dst, out, format); err != nil {
+		return nil, err
+	}
+	res.Width, res.Height = dst.Bounds().Dx(), dst.Bounds().Dy()
+	return res, nil
+}
+
+// parseArgs parses the flags in args, which may come before, between or
+// after the other arguments, and returns the other arguments.
+func parseArgs(args []string) (res []string) {
+	for {
+		flag.CommandLine.Parse(args)
+		if flag.NArg() == 0 {
+			return
+		}
+		res = append(res, flag.Arg(0))
+		args = flag.Args()[1:]
+	}
+}
+
+// transformTiles sends src to the server tile by tile and returns the
+// transformed image. A failed transfer is reported as a *transfer.Error.
+func transformTiles(ctx context.Context, client pb.TransformClient, src *image.NRGBA, steps *pb.Pipeline) (*image.NRGBA, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		filter.MetadataWidth, strconv.Itoa(src.Bounds().Dx()),
+		filter.MetadataHeight, strconv.Itoa(src.Bounds().Dy()),
+		cache.MetadataDigest, cache.Digest(src.Pix),
+		filter.MetadataOrdered, strconv.FormatBool(*ordered),
+	)
+
+	// only the pixels of a region are sent, the server would send the
+	// others back unchanged
+	bounds := regionBounds(steps.GetRegion(), src.Bounds())
+	rects := tileRects(bounds, *tileWidth, *tileHeight)
+	i := 0
+	next := func() *pb.Tile {
+		if i == len(rects) {
+			return nil
+		}
+		tile := cutTile(src, rects[i])
+		if i == 0 {
+			tile.Pipeline = steps
+		}
+		i++
+		return tile
+	}
+
+	var (
+		dst      *image.NRGBA
+		coverage *integrity.Coverage
+	)
+	bar := newProgress("transform", "pixels", bounds.Dx()*bounds.Dy())
+	defer bar.Finish()
+	t := &transfer.Transfer{
+		Total:  bounds.Dx() * bounds.Dy(),
+		Window: *window,
+		Verify: *verify,
+		// the server announces the size of the result before its first tile
+		Header: func(header metadata.MD) error {
+			dst = image.NewNRGBA(outputBounds(header, src.Bounds()))
+			coverage = integrity.NewCoverage(dst.Bounds())
+			if steps.GetRegion() != nil {
+				copy(dst.Pix, src.Pix)
+				coverage = integrity.NewCoverage(bounds)
+			} else {
+				bar.SetTotal(int64(len(dst.Pix) / 4))
 			}
-			stream.Send(&sendPixel)
-			log.Printf("Sending... %d/%d", y*width+x, height*width)
+			return nil
+		},
+		Progress: func(sent, confirmed int) {
+			bar.Set(int64(confirmed))
+		},
+		ProgressInterval: *serverProg,
+		ServerProgress: func(p *pb.Progress) {
+			bar.SetNote(fmt.Sprintf("server received %d/%d, sent %d", p.Received, p.Total, p.Sent))
+		},
+	}
+	trailer, err := t.Tiles(ctx, client, next, func(tile *pb.Tile) error {
+		pasteTile(dst, tile)
+		coverage.AddTile(tile)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if *verify {
+		if err := coverage.Check(); err != nil {
+			return nil, err
 		}
 	}
+	logCache(trailer)
+	return dst, nil
+}
+
+// outputBounds returns the bounds of the image announced in the response
+// header of a TransformTiles stream, or src when the server did not announce
+// a size.
+func outputBounds(header metadata.MD, src image.Rectangle) image.Rectangle {
+	w, errW := strconv.Atoi(first(header, filter.MetadataOutputWidth))
+	h, errH := strconv.Atoi(first(header, filter.MetadataOutputHeight))
+	if errW != nil || errH != nil || w < 1 || h < 1 {
+		return src
+	}
+	return image.Rect(0, 0, w, h)
+}
 
-	stream.CloseSend()
-	<-waitc
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
 
-	newImage := vectorToImage(newImageV)
-	err = saveImageToFilePath(newImage, "images/result.jpg")
+// transformFile sends the image file at path to the server without decoding
+// it and writes the transformed file the server returns to out, encoded in
+// format, returning the response without the file. Files larger than
+// chunkSize are uploaded in chunks.
+func transformFile(ctx context.Context, client pb.TransformClient, path string, steps *pb.Pipeline, out, format string) (*pb.ImageResponse, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Failed to save image : %v", err)
+		return nil, err
 	}
 
+	var res *pb.ImageResponse
+	var trailer metadata.MD
+	if len(data) <= chunkSize {
+		res, err = client.TransformImage(ctx, &pb.ImageRequest{
+			Image:        data,
+			Pipeline:     steps,
+			OutputFormat: format,
+			Quality:      int32(*quality),
+		}, grpc.MaxCallRecvMsgSize(maxResponseSize), grpc.Trailer(&trailer))
+	} else {
+		res, trailer, err = uploadFile(ctx, client, data, steps, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logCache(trailer)
+	if err := ioutil.WriteFile(out, res.Image, 0644); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func uploadFile(ctx context.Context, client pb.TransformClient, data []byte, steps *pb.Pipeline, format string) (*pb.ImageResponse, metadata.MD, error) {
+	stream, err := client.UploadImage(ctx, grpc.MaxCallRecvMsgSize(maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	bar := newProgress("upload", "bytes", len(data))
+	sendChunks(stream, data, steps, format, bar)
+	bar.Finish()
+	res, err := stream.CloseAndRecv()
+	return res, stream.Trailer(), err
+}
+
+// logCache logs whether the server answered from its cache, when it has
+// one.
+func logCache(trailer metadata.MD) {
+	if v := first(trailer, cache.TrailerStatus); v != "" {
+		log.Printf("Cache %s", v)
+	}
+}
+
+// sendChunks sends data in chunks of chunkSize, the first one carrying the
+// pipeline and output format. It stops at the first error, which the
+// caller learns from closing the stream. The bytes sent are reported to bar.
+func sendChunks(stream interface{ Send(*pb.ImageChunk) error }, data []byte, steps *pb.Pipeline, format string, bar *progress.Reporter) {
+	for i := 0; i == 0 || i < len(data); i += chunkSize {
+		end := i + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := &pb.ImageChunk{Data: data[i:end]}
+		if i == 0 {
+			chunk.Pipeline = steps
+			chunk.OutputFormat = format
+			chunk.Quality = int32(*quality)
+		}
+		if err := stream.Send(chunk); err != nil {
+			return
+		}
+		bar.Set(int64(i + len(chunk.Data)))
+	}
 }
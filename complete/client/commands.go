@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// sayHello greets the server with the name in args, joined by spaces.
+func sayHello(ctx context.Context, client pb.TransformClient, args []string) error {
+	name := strings.Join(args, " ")
+	if name == "" {
+		name = "world"
+	}
+	r, err := client.SayHello(ctx, &pb.HelloRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return emit(r, func(w io.Writer) {
+		fmt.Fprintln(w, r.GetMessage())
+	})
+}
+
+// simulatedMessages are the errors SimulateError is asked for when none is
+// given, see the error-handling server.
+var simulatedMessages = []string{"invalid argument", "timeout", "detail", "success"}
+
+// simulation is the outcome of a SimulateError call.
+type simulation struct {
+	Message    string      `json:"message"`
+	Code       string      `json:"code"`
+	Error      string      `json:"error,omitempty"`
+	Violations []violation `json:"violations,omitempty"`
+	Response   string      `json:"response,omitempty"`
+}
+
+type violation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// simulateErrors asks the server for the error of every message of args,
+// of simulatedMessages when there is none, and prints what it returned.
+// Errors are the expected outcome and do not fail the command.
+func simulateErrors(ctx context.Context, client pb.TransformClient, args []string) error {
+	if len(args) == 0 {
+		args = simulatedMessages
+	}
+	for _, msg := range args {
+		r, err := client.SimulateError(ctx, &pb.ErrorHandlingRequest{Message: msg})
+		st := status.Convert(err)
+		res := simulation{Message: msg, Code: st.Code().String(), Error: st.Message(), Response: r.GetMessage()}
+		for _, d := range st.Details() {
+			if br, ok := d.(*errdetails.BadRequest); ok {
+				for _, v := range br.GetFieldViolations() {
+					res.Violations = append(res.Violations, violation{Field: v.GetField(), Description: v.GetDescription()})
+				}
+			}
+		}
+		err = emit(res, func(w io.Writer) {
+			if res.Error == "" {
+				fmt.Fprintf(w, "%s: %s %s\n", res.Message, res.Code, res.Response)
+				return
+			}
+			fmt.Fprintf(w, "%s: %s %s\n", res.Message, res.Code, res.Error)
+			for _, v := range res.Violations {
+				fmt.Fprintf(w, "  %s: %s\n", v.Field, v.Description)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkHealth asks the health service of the server about the service named
+// by args, the server as a whole when there is none. It fails unless the
+// service is serving.
+func checkHealth(ctx context.Context, conn *grpc.ClientConn, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("health takes at most one service name, got %d", len(args))
+	}
+	req := &healthpb.HealthCheckRequest{}
+	if len(args) == 1 {
+		req.Service = args[0]
+	}
+	r, err := healthpb.NewHealthClient(conn).Check(ctx, req)
+	if err != nil {
+		return err
+	}
+	if err := emit(r, func(w io.Writer) {
+		fmt.Fprintln(w, r.Status)
+	}); err != nil {
+		return err
+	}
+	if r.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("service %q is %s", req.Service, r.Status)
+	}
+	return nil
+}
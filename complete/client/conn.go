@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"nichowil/grpc-tutorial/interceptor"
+	"nichowil/grpc-tutorial/metrics"
+	"nichowil/grpc-tutorial/tracing"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials/oauth"
+)
+
+// tokenEnv names the environment variable holding the bearer token when
+// -token is not set, which keeps it out of the shell history.
+const tokenEnv = "TRANSFORM_TOKEN"
+
+var (
+	useTLS      = flag.Bool("tls", false, "Connect over TLS, verifying the server against the system roots or -ca")
+	caFile      = flag.String("ca", "", "File of the CA certificate the server is verified against, implies -tls")
+	serverName  = flag.String("server-name", "", "Name the server certificate is verified against, the host of -addr when empty")
+	certFile    = flag.String("cert", "", "File of the client certificate, for servers requiring one, implies -tls")
+	keyFile     = flag.String("key", "", "File of the key of -cert")
+	token       = flag.String("token", "", "Bearer token sent with every call, $"+tokenEnv+" when empty, requires -tls")
+	logCalls    = flag.Bool("log-calls", false, "Log every call as a line of JSON on the standard error")
+	metricsAddr = flag.String("metrics-addr", "", "Address of the HTTP server exposing the metrics of the calls at /metrics while the command runs, such as :9091, none when empty")
+)
+
+// dialOptions returns the options connecting to the server as the flags ask,
+// tracing the calls with t unless it is nil.
+func dialOptions(t *tracing.Provider) ([]grpc.DialOption, error) {
+	opts, err := credentialOptions()
+	if err != nil {
+		return nil, err
+	}
+	if *metricsAddr != "" {
+		r := metrics.NewRegistry()
+		ms, err := metrics.Serve(*metricsAddr, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serve metrics: %v", err)
+		}
+		log.Printf("Metrics served at http://%v/metrics", ms.Addr)
+		m := interceptor.NewMetrics(r)
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(m.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(m.StreamClientInterceptor()),
+		)
+	}
+	if t != nil {
+		// before logging, which logs the trace of every call
+		tr := interceptor.NewTracing(t)
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(tr.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(tr.StreamClientInterceptor()),
+		)
+	}
+	if *logCalls {
+		l := interceptor.NewLogger(os.Stderr)
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(l.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(l.StreamClientInterceptor()),
+		)
+	}
+	return opts, nil
+}
+
+// credentialOptions returns the options securing the connection and
+// authenticating the calls.
+func credentialOptions() ([]grpc.DialOption, error) {
+	tok := *token
+	if tok == "" {
+		tok = os.Getenv(tokenEnv)
+	}
+	if !*useTLS && *caFile == "" && *certFile == "" {
+		if tok != "" {
+			// the token would travel in the clear
+			return nil, errors.New("a bearer token requires -tls or -ca")
+		}
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+
+	config := &tls.Config{ServerName: *serverName}
+	if *caFile != "" {
+		pem, err := ioutil.ReadFile(*caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", *caFile)
+		}
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(config))}
+	if tok != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(oauth.TokenSource{
+			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tok}),
+		}))
+	}
+	return opts, nil
+}
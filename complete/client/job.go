@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"google.golang.org/grpc"
+
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// runJob runs the job command given by args: "submit" sends the image file
+// at -img as a new job, "status", "watch", "cancel" and "fetch" take the ID
+// of a job.
+func runJob(ctx context.Context, client pb.TransformClient, args []string, steps *pb.Pipeline, format string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected submit, status, watch, cancel or fetch")
+	}
+	if args[0] == "submit" {
+		j, err := submitJob(ctx, client, *imagePath, steps, format)
+		if err != nil {
+			return err
+		}
+		if err := printJob(j); err != nil {
+			return err
+		}
+		if !*wait {
+			return nil
+		}
+		if err := watchJob(ctx, client, j.Id); err != nil {
+			return err
+		}
+		return fetchResult(ctx, client, j.Id, *outPath)
+	}
+
+	if len(args) != 2 {
+		return fmt.Errorf("%s needs the ID of a job", args[0])
+	}
+	req := &pb.JobRequest{Id: args[1]}
+	switch args[0] {
+	case "status":
+		j, err := client.GetJob(ctx, req)
+		if err != nil {
+			return err
+		}
+		return printJob(j)
+	case "watch":
+		return watchJob(ctx, client, req.Id)
+	case "cancel":
+		j, err := client.CancelJob(ctx, req)
+		if err != nil {
+			return err
+		}
+		return printJob(j)
+	case "fetch":
+		return fetchResult(ctx, client, req.Id, *outPath)
+	}
+	return fmt.Errorf("unknown job command %q", args[0])
+}
+
+func submitJob(ctx context.Context, client pb.TransformClient, path string, steps *pb.Pipeline, format string) (*pb.Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := client.SubmitJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bar := newProgress("upload", "bytes", len(data))
+	sendChunks(stream, data, steps, format, bar)
+	bar.Finish()
+	return stream.CloseAndRecv()
+}
+
+// watchJob prints the state of a job until it ends, failing unless it is
+// done.
+func watchJob(ctx context.Context, client pb.TransformClient, id string) error {
+	stream, err := client.WatchJob(ctx, &pb.JobRequest{Id: id})
+	if err != nil {
+		return err
+	}
+	var last *pb.Job
+	for {
+		j, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := printJob(j); err != nil {
+			return err
+		}
+		last = j
+	}
+	if last != nil && last.State != pb.Job_DONE {
+		return fmt.Errorf("job %s ended %s", id, last.State)
+	}
+	return nil
+}
+
+// fetchResult writes the result of a job that is done to path.
+func fetchResult(ctx context.Context, client pb.TransformClient, id, path string) error {
+	stream, err := client.FetchResult(ctx, &pb.JobRequest{Id: id}, grpc.MaxCallRecvMsgSize(maxResponseSize))
+	if err != nil {
+		return err
+	}
+	// the file is only created once the server confirmed the result exists
+	var f *os.File
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			if f, err = os.Create(path); err != nil {
+				return err
+			}
+			defer f.Close()
+		}
+		if _, err := f.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+	if f == nil {
+		return fmt.Errorf("job %s has no result", id)
+	}
+	return f.Close()
+}
+
+func printJob(j *pb.Job) error {
+	return emit(j, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %-9s %3.0f%%", j.Id, j.State, j.Progress*100)
+		switch {
+		case j.State == pb.Job_DONE:
+			fmt.Fprintf(w, "  %dx%d %s", j.Width, j.Height, j.Format)
+		case j.Error != "":
+			fmt.Fprintf(w, "  %s", j.Error)
+		}
+		fmt.Fprintln(w)
+	})
+}
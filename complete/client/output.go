@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var outputFlag = flag.String("o", "text", "How results are printed on stdout: text or json")
+
+// jsonOutput is set when results are printed as JSON, one object per line.
+var jsonOutput bool
+
+func parseOutput(s string) error {
+	switch s {
+	case "text":
+		jsonOutput = false
+	case "json":
+		jsonOutput = true
+	default:
+		return fmt.Errorf("unknown output %q, expected text or json", s)
+	}
+	return nil
+}
+
+// emit prints the result v on stdout: with text when printing text, and as
+// JSON otherwise, using the JSON mapping of messages.
+func emit(v interface{}, text func(w io.Writer)) error {
+	if !jsonOutput {
+		text(os.Stdout)
+		return nil
+	}
+	var (
+		b   []byte
+		err error
+	)
+	if m, ok := v.(proto.Message); ok {
+		b, err = protojson.Marshal(m)
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("%s\n", b)
+	return err
+}
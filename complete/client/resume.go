@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"time"
+
+	"nichowil/grpc-tutorial/transfer"
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// transformResumable sends src to the server over a resumable transfer and
+// returns the transformed image. When the connection drops the transfer
+// continues on a new stream from the last rows the server acknowledged.
+func transformResumable(ctx context.Context, client pb.TransformClient, src *image.NRGBA, steps *pb.Pipeline) (*image.NRGBA, error) {
+	if steps.GetRegion() != nil {
+		return nil, errors.New("resumable transfers do not support -region")
+	}
+	bounds := src.Bounds()
+	var dst *image.NRGBA
+	bar := newProgress("transfer", "rows", bounds.Dy())
+	defer bar.Finish()
+	r := &transfer.Resumable{
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Pipeline: steps,
+		Rows: func(lo, hi int) []byte {
+			return cutTile(src, image.Rect(bounds.Min.X, bounds.Min.Y+lo, bounds.Max.X, bounds.Min.Y+hi)).Rgba
+		},
+		TileHeight: *tileHeight,
+		Size: func(width, height int) error {
+			dst = image.NewNRGBA(image.Rect(0, 0, width, height))
+			bar.SetTotal(int64(height))
+			return nil
+		},
+		Recv: func(rows *pb.Tile) error {
+			pasteTile(dst, rows)
+			return nil
+		},
+		Retries: *retries,
+		Progress: func(acked, received int) {
+			bar.SetNote(fmt.Sprintf("server acknowledged %d/%d rows", acked, bounds.Dy()))
+			bar.Set(int64(received))
+		},
+	}
+	r.Retry = func(err error, wait time.Duration) {
+		log.Printf("Transfer %s interrupted: %v, resuming in %v", r.ID, err, wait)
+	}
+	if err := r.Run(ctx, client); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
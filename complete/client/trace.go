@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"nichowil/grpc-tutorial/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var traceTo = flag.String("trace", "", "Where the spans of the calls are exported: stdout, stderr, file:PATH, otlp or the URL of an OTLP collector, nowhere when empty")
+
+// newTracer returns the provider of the spans of -trace, nil when calls are
+// not traced.
+func newTracer() (*tracing.Provider, error) {
+	if *traceTo == "" {
+		return nil, nil
+	}
+	return tracing.NewProvider("transform-client", *traceTo)
+}
+
+// startCommand starts the span of command with t, which the calls it makes
+// are children of, so that they make one trace. A watch runs until
+// interrupted, its calls make a trace each instead. The span does nothing
+// when t is nil.
+func startCommand(ctx context.Context, t *tracing.Provider, command string) (context.Context, trace.Span) {
+	if t == nil || command == "watch" {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return t.Tracer(tracing.Scope).Start(ctx, command)
+}
+
+// endCommand ends the span of a command that ended with err, and exports
+// the spans that were not exported yet.
+func endCommand(t *tracing.Provider, span trace.Span, err error) {
+	tracing.End(span, err)
+	if t != nil {
+		t.Close()
+	}
+}
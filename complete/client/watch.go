@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"nichowil/grpc-tutorial/progress"
+	pb "nichowil/grpc-tutorial/transform"
+	"nichowil/grpc-tutorial/watch"
+)
+
+// maxRetryWait bounds the wait before a failed image is tried again.
+const maxRetryWait = 5 * time.Minute
+
+var (
+	ledgerPath   = flag.String("ledger", "", "File recording what watch did with every image, .transform-ledger.json in its output directory when empty")
+	attempts     = flag.Int("attempts", 5, "Number of times watch tries to transform an image before giving up on it until it changes")
+	retryBackoff = flag.Duration("retry-backoff", 2*time.Second, "Wait before watch tries a failed image again, doubled at every attempt")
+)
+
+// ledger records what a watch did with every image, so that a watch
+// restarted goes on where the previous one stopped.
+type ledger struct {
+	path  string
+	Files map[string]*ledgerEntry `json:"files"`
+}
+
+// ledgerEntry is what was done with one version of an image, told apart by
+// its size and modification time.
+type ledgerEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Done     bool      `json:"done,omitempty"`
+	Attempts int       `json:"attempts,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Retry is when the image is tried again after it failed, nil when
+	// it is not.
+	Retry *time.Time `json:"retry,omitempty"`
+}
+
+func loadLedger(path string) (*ledger, error) {
+	l := &ledger{path: path, Files: make(map[string]*ledgerEntry)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if l.Files == nil {
+		l.Files = make(map[string]*ledgerEntry)
+	}
+	return l, nil
+}
+
+// save writes the ledger to a new file replacing the previous one, which a
+// crash leaves whole.
+func (l *ledger) save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// entry returns the entry of the image rel described by info, a new one
+// when the image changed since it was recorded.
+func (l *ledger) entry(rel string, info os.FileInfo) *ledgerEntry {
+	e := l.Files[rel]
+	if e == nil || e.Size != info.Size() || !e.ModTime.Equal(info.ModTime()) {
+		e = &ledgerEntry{Size: info.Size(), ModTime: info.ModTime()}
+		l.Files[rel] = e
+	}
+	return e
+}
+
+// watchItem is the outcome of an attempt to transform an image of a watch.
+type watchItem struct {
+	batchItem
+	Attempt int `json:"attempt,omitempty"`
+	// RetryIn is the wait before the next attempt, empty when the image
+	// is given up on.
+	RetryIn string `json:"retry_in,omitempty"`
+}
+
+// watcher transforms the images of a directory as they are written. Its
+// fields belong to the goroutine running it.
+type watcher struct {
+	in, out string
+	include []string
+	exclude []string
+	ledger  *ledger
+
+	queue   []string
+	queued  map[string]bool // in the queue or being transformed
+	running map[string]bool
+	dirty   map[string]bool // changed while being transformed
+	retry   chan string
+}
+
+// runWatch transforms the images found in the directory args[0] into the
+// same tree under the directory args[1], as runBatch does, then watches
+// the directory and transforms the images written to it until interrupted.
+// Failed images are tried again with a growing wait. What was done is kept
+// in a ledger so that a restarted watch does not transform them again.
+func runWatch(ctx context.Context, client pb.TransformClient, args []string, steps *pb.Pipeline, format string) error {
+	if len(args) != 2 {
+		return errors.New("watch needs the directory of the images and the directory of the results")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			log.Println("Stopping once the images being transformed are done")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d := &watcher{
+		in:      args[0],
+		out:     args[1],
+		include: splitList(*include),
+		exclude: splitList(*exclude),
+		queued:  make(map[string]bool),
+		running: make(map[string]bool),
+		dirty:   make(map[string]bool),
+		retry:   make(chan string),
+	}
+	if err := os.MkdirAll(d.out, 0755); err != nil {
+		return err
+	}
+	path := *ledgerPath
+	if path == "" {
+		path = filepath.Join(d.out, ".transform-ledger.json")
+	}
+	var err error
+	if d.ledger, err = loadLedger(path); err != nil {
+		return err
+	}
+
+	// the directory is watched before it is scanned so that no image
+	// written meanwhile is missed
+	w, err := watch.New(d.in)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	paths, err := findImages(d.in, d.out, d.include, d.exclude)
+	if err != nil {
+		return err
+	}
+	for _, rel := range paths {
+		d.consider(ctx, rel)
+	}
+	// images are reported one by one, without a bar
+	progressMode = progress.Quiet
+	log.Printf("Watching %s", d.in)
+
+	n := *concurrency
+	if n < 1 {
+		n = 1
+	}
+	todo := make(chan string)
+	results := make(chan batchItem)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range todo {
+				results <- batchImage(ctx, client, d.in, d.out, rel, steps, format)
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		close(todo)
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		// interrupted transforms are not recorded, they are tried again
+		// when the watch restarts
+		for range results {
+		}
+	}()
+
+	errs := w.Errors
+	for {
+		var next chan string
+		if len(d.queue) > 0 {
+			next = todo
+		}
+		select {
+		case <-ctx.Done():
+			return d.ledger.save()
+		case next <- d.peek():
+			rel := d.queue[0]
+			d.queue = d.queue[1:]
+			d.running[rel] = true
+		case item := <-results:
+			if ctx.Err() != nil {
+				return d.ledger.save()
+			}
+			if err := d.done(ctx, item); err != nil {
+				return err
+			}
+		case rel := <-d.retry:
+			d.consider(ctx, rel)
+		case path, ok := <-w.Files:
+			if !ok {
+				return errors.New("the watch of the directory stopped")
+			}
+			if rel, ok := d.relative(path); ok {
+				d.consider(ctx, rel)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Printf("Watch: %v", err)
+		}
+	}
+}
+
+func (d *watcher) peek() string {
+	if len(d.queue) == 0 {
+		return ""
+	}
+	return d.queue[0]
+}
+
+// relative returns the path of the image at path within the directory
+// watched, unless it is not an image to transform.
+func (d *watcher) relative(path string) (string, bool) {
+	rel, err := filepath.Rel(d.in, path)
+	if err != nil {
+		return "", false
+	}
+	// results written within the directory watched
+	if out, err := filepath.Rel(d.in, d.out); err == nil && out != "." && !strings.HasPrefix(out, "..") {
+		if rel == out || strings.HasPrefix(rel, out+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+	return rel, matchAny(d.include, rel) && !matchAny(d.exclude, rel)
+}
+
+// consider queues the image rel unless the ledger says it was transformed
+// as it is, or was given up on, or is to be retried later.
+func (d *watcher) consider(ctx context.Context, rel string) {
+	if d.running[rel] {
+		d.dirty[rel] = true
+		return
+	}
+	if d.queued[rel] {
+		return
+	}
+	info, err := os.Stat(filepath.Join(d.in, rel))
+	if err != nil {
+		// removed since
+		return
+	}
+	e := d.ledger.entry(rel, info)
+	switch {
+	case e.Done || e.Attempts >= *attempts:
+	case e.Retry != nil && time.Now().Before(*e.Retry):
+		d.retryAfter(ctx, rel, time.Until(*e.Retry))
+	default:
+		d.queued[rel] = true
+		d.queue = append(d.queue, rel)
+	}
+}
+
+// retryAfter considers the image rel again after wait.
+func (d *watcher) retryAfter(ctx context.Context, rel string, wait time.Duration) {
+	time.AfterFunc(wait, func() {
+		select {
+		case d.retry <- rel:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// done records the outcome of an attempt and reports it.
+func (d *watcher) done(ctx context.Context, item batchItem) error {
+	rel, _ := filepath.Rel(d.in, item.Path)
+	delete(d.running, rel)
+	delete(d.queued, rel)
+
+	res := watchItem{batchItem: item}
+	if e := d.ledger.Files[rel]; e != nil {
+		if item.Status == "failed" {
+			e.Attempts++
+			e.Error = item.Code + ": " + item.Error
+			res.Attempt = e.Attempts
+			e.Retry = nil
+			if e.Attempts < *attempts {
+				wait := *retryBackoff << uint(e.Attempts-1)
+				if wait > maxRetryWait || wait <= 0 {
+					wait = maxRetryWait
+				}
+				retry := time.Now().Add(wait)
+				e.Retry = &retry
+				res.RetryIn = wait.String()
+				d.retryAfter(ctx, rel, wait)
+			}
+		} else {
+			e.Done, e.Attempts, e.Error, e.Retry = true, 0, "", nil
+		}
+		if err := d.ledger.save(); err != nil {
+			return err
+		}
+	}
+	if err := emit(res, func(w io.Writer) {
+		switch {
+		case item.Status != "failed":
+			fmt.Fprintf(w, "%-7s %s -> %s\n", item.Status, item.Path, item.Out)
+		case res.RetryIn != "":
+			fmt.Fprintf(w, "failed  %s: %s %s, attempt %d of %d, retrying in %s\n", item.Path, item.Code, item.Error, res.Attempt, *attempts, res.RetryIn)
+		default:
+			fmt.Fprintf(w, "failed  %s: %s %s, giving up after %d attempts\n", item.Path, item.Code, item.Error, res.Attempt)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if d.dirty[rel] {
+		delete(d.dirty, rel)
+		d.consider(ctx, rel)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+
+	"nichowil/grpc-tutorial/analyze"
+	"nichowil/grpc-tutorial/codec"
+	"nichowil/grpc-tutorial/filter"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) Analyze(stream pb.Transform_AnalyzeServer) error {
+	var (
+		first *pb.AnalyzeRequest
+		a     = analyze.New()
+		data  []byte
+	)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+			if n := req.GetColors(); n < 0 || n > analyze.MaxColors {
+				return status.Errorf(codes.InvalidArgument, "colors must be between 1 and %d, got %d", analyze.MaxColors, n)
+			}
+		}
+		if kind(req) != kind(first) {
+			return status.Error(codes.InvalidArgument, "every message of an Analyze stream must carry the same kind of input")
+		}
+
+		switch input := req.Input.(type) {
+		case *pb.AnalyzeRequest_Pixel:
+			if input.Pixel.GetColor() == nil {
+				return status.Error(codes.InvalidArgument, "pixel color is missing")
+			}
+			a.Add(filter.ColorFromProto(input.Pixel.Color))
+		case *pb.AnalyzeRequest_Tile:
+			if err := validateTile(input.Tile); err != nil {
+				return err
+			}
+			a.AddRGBA(input.Tile.Rgba)
+		case *pb.AnalyzeRequest_Chunk:
+			if len(data)+len(input.Chunk.Data) > maxUploadSize {
+				return status.Errorf(codes.ResourceExhausted, "image is larger than %d bytes", maxUploadSize)
+			}
+			data = append(data, input.Chunk.Data...)
+		default:
+			return status.Error(codes.InvalidArgument, "message carries no input")
+		}
+	}
+	if first == nil {
+		return status.Error(codes.InvalidArgument, "no image was sent")
+	}
+
+	var width, height int
+	if chunk := first.GetChunk(); chunk != nil {
+		img, _, err := codec.Decode(data, chunk.GetFormat())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "cannot decode image: %v", err)
+		}
+		f := filter.FrameFromImage(img)
+		width, height = f.Width, f.Height
+		a.AddFrame(f)
+	}
+
+	res, err := a.Result(int(first.GetColors()))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	res.Width, res.Height = int32(width), int32(height)
+	return stream.SendAndClose(res)
+}
+
+// kind returns the type of input carried by req.
+func kind(req *pb.AnalyzeRequest) string {
+	switch req.Input.(type) {
+	case *pb.AnalyzeRequest_Pixel:
+		return "pixel"
+	case *pb.AnalyzeRequest_Tile:
+		return "tile"
+	case *pb.AnalyzeRequest_Chunk:
+		return "chunk"
+	}
+	return ""
+}
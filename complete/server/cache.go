@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"nichowil/grpc-tutorial/cache"
+	"nichowil/grpc-tutorial/codec"
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/tracing"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// pipelineOf returns the pipeline a call asked for: p when set, otherwise
+// the filter selected by the metadata of ctx as a pipeline of one step.
+func pipelineOf(ctx context.Context, p *pb.Pipeline) (*pb.Pipeline, error) {
+	if p != nil {
+		return p, nil
+	}
+	step, err := filter.StepFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Pipeline{Steps: []*pb.Step{step}}, nil
+}
+
+// pipelineKey encodes p the same way every time, as part of a cache key.
+func pipelineKey(p *pb.Pipeline) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(p)
+}
+
+// cacheTrailer reports whether a result came from the cache.
+func cacheTrailer(hit bool) metadata.MD {
+	if hit {
+		return metadata.Pairs(cache.TrailerStatus, "hit")
+	}
+	return metadata.Pairs(cache.TrailerStatus, "miss")
+}
+
+// transformCached is transformEncoded, returning the result cached for the
+// same file, pipeline and output when there is one. It reports whether it
+// did.
+func (s *server) transformCached(ctx context.Context, in *pb.ImageChunk, progress func(float64)) (*pb.ImageResponse, bool, error) {
+	if s.cache == nil {
+		res, err := transformEncoded(ctx, in, progress)
+		return res, false, err
+	}
+	p, err := pipelineOf(ctx, in.Pipeline)
+	if err != nil {
+		return nil, false, err
+	}
+	pk, err := pipelineKey(p)
+	if err != nil {
+		return nil, false, status.Error(codes.Internal, err.Error())
+	}
+	key := cache.Key(
+		[]byte("encoded"),
+		in.Data,
+		[]byte(codec.Normalize(in.Format)),
+		pk,
+		[]byte(codec.Normalize(in.OutputFormat)),
+		[]byte(strconv.Itoa(int(in.Quality))),
+	)
+
+	_, span := tracing.Start(ctx, "cache.lookup")
+	v, ok := s.cache.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	span.End()
+	if ok {
+		res := &pb.ImageResponse{}
+		if err := proto.Unmarshal(v, res); err == nil {
+			if progress != nil {
+				progress(1)
+			}
+			return res, true, nil
+		}
+	}
+	res, err := transformEncoded(ctx, in, progress)
+	if err != nil {
+		return nil, false, err
+	}
+	if v, err := proto.Marshal(res); err == nil {
+		s.cache.Put(key, v)
+	}
+	return res, false, nil
+}
+
+// streamCache caches the result of a Transform or TransformTiles stream by
+// the digest of the pixels it receives. Only the clients declaring the
+// digest of their image in the metadata may find a cached result, so only
+// their streams are cached: they get the result cached for the digest once
+// the pixels they sent are checked to match, or cache the result sent.
+type streamCache struct {
+	cache    *cache.Cache
+	pipeline []byte
+	src      *pb.Tile // the image received
+	dst      *pb.Tile // the image sent back
+	declared string
+	// result is the image cached for the declared digest, sent back
+	// instead of running the pipeline.
+	result *pb.Tile
+}
+
+// newStreamCache returns the cache of a stream running p over an image of
+// width by height pixels, giving one of outWidth by outHeight pixels. It
+// returns nil when the server has no cache, the size of the image is not
+// known or the client did not declare its digest, sparing a copy of the
+// image and of the result.
+func (s *server) newStreamCache(ctx context.Context, p *pb.Pipeline, width, height, outWidth, outHeight int) (*streamCache, error) {
+	if s.cache == nil || width == 0 {
+		return nil, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	declared := md.Get(cache.MetadataDigest)
+	if len(declared) == 0 {
+		return nil, nil
+	}
+	p, err := pipelineOf(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := pipelineKey(p)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	c := &streamCache{
+		cache:    s.cache,
+		pipeline: pk,
+		src:      newTile(width, height),
+		dst:      newTile(outWidth, outHeight),
+		declared: declared[0],
+	}
+	if v, ok := s.cache.Get(c.key(c.declared)); ok {
+		result := &pb.Tile{}
+		if err := proto.Unmarshal(v, result); err == nil {
+			c.result = result
+		}
+	}
+	return c, nil
+}
+
+func newTile(width, height int) *pb.Tile {
+	return &pb.Tile{
+		Origin: &pb.Point{},
+		Width:  int32(width),
+		Height: int32(height),
+		Rgba:   make([]byte, width*height*4),
+	}
+}
+
+// key returns the cache key of the image with the given digest.
+func (c *streamCache) key(digest string) string {
+	return cache.Key(
+		[]byte("rgba"),
+		[]byte(digest),
+		[]byte(fmt.Sprintf("%dx%d", c.src.Width, c.src.Height)),
+		c.pipeline,
+	)
+}
+
+// hit reports whether the result of the stream is cached.
+func (c *streamCache) hit() bool {
+	return c != nil && c.result != nil
+}
+
+func (c *streamCache) receivedPixel(p *pb.Pixel) {
+	if c != nil {
+		paste(c.src, int(p.Point.GetX()), int(p.Point.GetY()), 1, pixelRGBA(p))
+	}
+}
+
+func (c *streamCache) receivedTile(t *pb.Tile) {
+	if c != nil {
+		paste(c.src, int(t.Origin.GetX()), int(t.Origin.GetY()), int(t.Width), t.Rgba)
+	}
+}
+
+func (c *streamCache) sentPixel(p *pb.Pixel) {
+	if c != nil {
+		paste(c.dst, int(p.Point.GetX()), int(p.Point.GetY()), 1, pixelRGBA(p))
+	}
+}
+
+func (c *streamCache) sentTile(t *pb.Tile) {
+	if c != nil {
+		paste(c.dst, int(t.Origin.GetX()), int(t.Origin.GetY()), int(t.Width), t.Rgba)
+	}
+}
+
+// finish ends the stream once every pixel was received, before sending the
+// cached result if there is one. It checks that the pixels match the
+// declared digest before the cached result is sent, and caches the result
+// that was sent otherwise.
+func (c *streamCache) finish(stream grpc.ServerStream) error {
+	if c == nil {
+		return nil
+	}
+	digest := cache.Digest(c.src.Rgba)
+	if c.result != nil {
+		if digest != c.declared {
+			return status.Errorf(codes.InvalidArgument, "%s does not match the pixels sent", cache.MetadataDigest)
+		}
+		stream.SetTrailer(cacheTrailer(true))
+		return nil
+	}
+	if v, err := proto.Marshal(c.dst); err == nil {
+		c.cache.Put(c.key(digest), v)
+	}
+	stream.SetTrailer(cacheTrailer(false))
+	return nil
+}
+
+func pixelRGBA(p *pb.Pixel) []byte {
+	b := make([]byte, 4)
+	filter.PackRGBA(b, []filter.Color{filter.ColorFromProto(p.Color)})
+	return b
+}
+
+// paste copies rgba, the packed pixels of a block width pixels wide at
+// (x, y), into dst, dropping anything outside of it.
+func paste(dst *pb.Tile, x, y, width int, rgba []byte) {
+	if width <= 0 {
+		return
+	}
+	w, h := int(dst.Width), int(dst.Height)
+	for row := 0; row*width*4 < len(rgba); row++ {
+		ty := y + row
+		if ty < 0 || ty >= h {
+			continue
+		}
+		lo, hi := x, x+width
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > w {
+			hi = w
+		}
+		if lo >= hi {
+			return
+		}
+		src := rgba[(row*width+lo-x)*4 : (row*width+hi-x)*4]
+		copy(dst.Rgba[(ty*w+lo)*4:], src)
+	}
+}
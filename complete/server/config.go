@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the names of the environment variables overriding the
+// configuration, followed by the path of the option in upper case:
+// TRANSFORM_CACHE_DIR overrides cache.dir. Lists are comma separated.
+const envPrefix = "TRANSFORM_"
+
+// config is the configuration of the server. It is read from the file of
+// -config, then overridden by the environment and by the flags set on the
+// command line, see load.
+type config struct {
+	Port          int           `yaml:"port"`
+	Workers       int           `yaml:"workers"`
+	StreamWorkers int           `yaml:"stream_workers"`
+	Queue         int           `yaml:"queue"`
+	JobRetention  time.Duration `yaml:"job_retention"`
+	JobDB         string        `yaml:"job_db"`
+	TransferGrace time.Duration `yaml:"transfer_grace"`
+	MaxTransfers  int           `yaml:"max_transfers"`
+
+	Cache struct {
+		Size     int64  `yaml:"size"`
+		Dir      string `yaml:"dir"`
+		DiskSize int64  `yaml:"disk_size"`
+	} `yaml:"cache"`
+
+	// TLS serves over TLS when Cert and Key are set, and requires client
+	// certificates signed by ClientCA when it is set as well.
+	TLS struct {
+		Cert     string `yaml:"cert"`
+		Key      string `yaml:"key"`
+		ClientCA string `yaml:"client_ca"`
+	} `yaml:"tls"`
+
+	// Auth requires calls to carry one of Tokens as a bearer token, when
+	// there are any. Health checks do not.
+	Auth struct {
+		Tokens []string `yaml:"tokens"`
+	} `yaml:"auth"`
+
+	Interceptors struct {
+		// Logging logs every call as a line of JSON on the standard error.
+		Logging bool `yaml:"logging"`
+		// Timeout and StreamTimeout bound unary calls and streams, which
+		// are not bounded when 0. MethodTimeouts bounds the calls of
+		// methods by full name instead, see interceptor.Deadlines.
+		Timeout        time.Duration            `yaml:"timeout"`
+		StreamTimeout  time.Duration            `yaml:"stream_timeout"`
+		MethodTimeouts map[string]time.Duration `yaml:"method_timeouts"`
+	} `yaml:"interceptors"`
+
+	// Metrics serves the metrics of the server at /metrics on Addr, in
+	// the text format of Prometheus, unless it is empty.
+	Metrics struct {
+		Addr string `yaml:"addr"`
+	} `yaml:"metrics"`
+
+	// Tracing traces every call, and the stages of the transforms of
+	// encoded images, in spans exported as Exporter tells, see
+	// tracing.NewProvider, unless it is empty. Service names the server in
+	// the spans.
+	Tracing struct {
+		Exporter string `yaml:"exporter"`
+		Service  string `yaml:"service"`
+	} `yaml:"tracing"`
+
+	// Reflection registers the reflection service, for tools such as
+	// grpcurl.
+	Reflection bool `yaml:"reflection"`
+	// Health registers the health service.
+	Health bool `yaml:"health"`
+}
+
+// defaultConfig returns the configuration of a server started without a
+// file, environment or flags.
+func defaultConfig() *config {
+	c := &config{
+		Port:          50051,
+		Workers:       runtime.NumCPU(),
+		StreamWorkers: runtime.NumCPU(),
+		Queue:         64,
+		JobRetention:  time.Hour,
+		TransferGrace: 10 * time.Minute,
+		MaxTransfers:  16,
+		Health:        true,
+	}
+	c.Tracing.Service = "transform-server"
+	c.Cache.Size = 256 << 20
+	c.Cache.DiskSize = 4 << 30
+	return c
+}
+
+// bindFlags defines the flags of the options that have one, defaulting to
+// their value in c.
+func (c *config) bindFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Port, "port", c.Port, "The server port")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "Number of jobs transformed at the same time")
+	fs.IntVar(&c.StreamWorkers, "stream-workers", c.StreamWorkers, "Number of goroutines filtering the pixels of each Transform stream")
+	fs.IntVar(&c.Queue, "queue", c.Queue, "Number of submitted jobs that may wait for a worker")
+	fs.DurationVar(&c.JobRetention, "job-retention", c.JobRetention, "How long the result of a job is kept once it ended")
+	fs.Int64Var(&c.Cache.Size, "cache-size", c.Cache.Size, "Bytes of results cached in memory, 0 disables the cache")
+	fs.StringVar(&c.Cache.Dir, "cache-dir", c.Cache.Dir, "Directory caching results on disk as well, when not empty")
+	fs.Int64Var(&c.Cache.DiskSize, "cache-disk-size", c.Cache.DiskSize, "Bytes of results cached in -cache-dir")
+	fs.DurationVar(&c.TransferGrace, "transfer-grace", c.TransferGrace, "How long the partial state of a transfer is kept for the client to resume it after its stream ended")
+	fs.IntVar(&c.MaxTransfers, "max-transfers", c.MaxTransfers, "Number of transfers kept at the same time, running or waiting to be resumed, each holding its image and result")
+	fs.StringVar(&c.JobDB, "job-db", c.JobDB, "File of the database keeping jobs across restarts, jobs are kept in memory when empty")
+	fs.StringVar(&c.Tracing.Exporter, "trace", c.Tracing.Exporter, "Where spans are exported: stdout, stderr, file:PATH, otlp or the URL of an OTLP collector, nowhere when empty")
+	fs.StringVar(&c.Metrics.Addr, "metrics-addr", c.Metrics.Addr, "Address of the HTTP server exposing the metrics at /metrics, such as :9090, none when empty")
+}
+
+// load overrides c with the YAML file at path, unless path is empty, then
+// with the environment variables found by lookup. Unknown options in the
+// file are errors.
+func (c *config) load(path string, lookup func(string) (string, bool)) error {
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// an empty file decodes to io.EOF and changes nothing
+		if err := dec.Decode(c); err != nil && len(bytes.TrimSpace(data)) > 0 {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return overrideFromEnv(reflect.ValueOf(c).Elem(), envPrefix, lookup)
+}
+
+// overrideFromEnv sets the fields of the struct v whose variable, named
+// after prefix and their YAML key, is set.
+func overrideFromEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := prefix + strings.ToUpper(strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := overrideFromEnv(field, key+"_", lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		s, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(s)
+		case reflect.Slice:
+			var list []string
+			for _, item := range strings.Split(s, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		default:
+			if err := yaml.Unmarshal([]byte(s), field.Addr().Interface()); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate reports every option of c that is invalid, or that does not make
+// sense along with the others.
+func (c *config) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	check(c.Port >= 0 && c.Port <= 65535, "port %d is not between 0 and 65535", c.Port)
+	check(c.Workers >= 1, "workers must be at least 1, got %d", c.Workers)
+	check(c.StreamWorkers >= 1, "stream_workers must be at least 1, got %d", c.StreamWorkers)
+	check(c.Queue >= 0, "queue must not be negative, got %d", c.Queue)
+	check(c.JobRetention > 0, "job_retention must be positive, got %v", c.JobRetention)
+	check(c.TransferGrace > 0, "transfer_grace must be positive, got %v", c.TransferGrace)
+	check(c.MaxTransfers >= 1, "max_transfers must be at least 1, got %d", c.MaxTransfers)
+	check(c.Cache.Size >= 0, "cache.size must not be negative, got %d", c.Cache.Size)
+	check(c.Cache.Dir == "" || c.Cache.Size > 0, "cache.dir is set but the cache is disabled by cache.size")
+	check(c.Cache.Dir == "" || c.Cache.DiskSize > 0, "cache.disk_size must be positive when cache.dir is set, got %d", c.Cache.DiskSize)
+	check((c.TLS.Cert == "") == (c.TLS.Key == ""), "tls.cert and tls.key must be set together")
+	check(c.TLS.ClientCA == "" || c.TLS.Cert != "", "tls.client_ca requires tls.cert and tls.key")
+	// tokens would travel in the clear
+	check(len(c.Auth.Tokens) == 0 || c.TLS.Cert != "", "auth.tokens requires tls.cert and tls.key")
+	for i, token := range c.Auth.Tokens {
+		check(token != "", "auth.tokens[%d] is empty", i)
+	}
+	check(c.Interceptors.Timeout >= 0, "interceptors.timeout must not be negative, got %v", c.Interceptors.Timeout)
+	check(c.Interceptors.StreamTimeout >= 0, "interceptors.stream_timeout must not be negative, got %v", c.Interceptors.StreamTimeout)
+	for method, timeout := range c.Interceptors.MethodTimeouts {
+		check(strings.HasPrefix(method, "/") && strings.Count(method, "/") == 2, "interceptors.method_timeouts: %q is not a full method name such as /transform.Transform/SayHello", method)
+		check(timeout >= 0, "interceptors.method_timeouts: the timeout of %s must not be negative, got %v", method, timeout)
+	}
+	check(c.Tracing.Exporter == "" || c.Tracing.Service != "", "tracing.service must be set when tracing.exporter is")
+	if c.Metrics.Addr != "" {
+		_, port, err := net.SplitHostPort(c.Metrics.Addr)
+		check(err == nil, "metrics.addr: %q is not a host:port address", c.Metrics.Addr)
+		check(err != nil || port != strconv.Itoa(c.Port), "metrics.addr uses port %d of the gRPC server", c.Port)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// credentials returns the transport credentials of the TLS options, nil
+// when TLS is disabled.
+func (c *config) credentials() (credentials.TransportCredentials, error) {
+	if c.TLS.Cert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key pair: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if c.TLS.ClientCA != "" {
+		pem, err := ioutil.ReadFile(c.TLS.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.TLS.ClientCA)
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(config), nil
+}
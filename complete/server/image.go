@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"nichowil/grpc-tutorial/codec"
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/tracing"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxUploadSize bounds the size of a file sent to UploadImage.
+const maxUploadSize = 64 << 20
+
+func (s *server) TransformImage(ctx context.Context, in *pb.ImageRequest) (*pb.ImageResponse, error) {
+	chunk := &pb.ImageChunk{
+		Data:         in.GetImage(),
+		Format:       in.GetFormat(),
+		Pipeline:     in.GetPipeline(),
+		OutputFormat: in.GetOutputFormat(),
+		Quality:      in.GetQuality(),
+	}
+	res, hit, err := s.transformCached(ctx, chunk, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.image("TransformImage", chunk, res)
+	if s.cache != nil {
+		grpc.SetTrailer(ctx, cacheTrailer(hit))
+	}
+	return res, nil
+}
+
+func (s *server) UploadImage(stream pb.Transform_UploadImageServer) error {
+	in, err := receiveChunks(stream)
+	if err != nil {
+		return err
+	}
+	res, hit, err := s.transformCached(stream.Context(), in, nil)
+	if err != nil {
+		return err
+	}
+	s.metrics.image("UploadImage", in, res)
+	if s.cache != nil {
+		stream.SetTrailer(cacheTrailer(hit))
+	}
+	return stream.SendAndClose(res)
+}
+
+// chunkReceiver is the receiving side of a stream of image chunks.
+type chunkReceiver interface {
+	Recv() (*pb.ImageChunk, error)
+}
+
+// receiveChunks reads an image file uploaded in chunks until the client
+// closes the stream. It returns the first chunk holding the whole file.
+func receiveChunks(stream chunkReceiver) (*pb.ImageChunk, error) {
+	var (
+		first *pb.ImageChunk
+		data  []byte
+	)
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = chunk
+		}
+		if len(data)+len(chunk.Data) > maxUploadSize {
+			return nil, status.Errorf(codes.ResourceExhausted, "image is larger than %d bytes", maxUploadSize)
+		}
+		data = append(data, chunk.Data...)
+	}
+	if first == nil {
+		return nil, status.Error(codes.InvalidArgument, "no image was uploaded")
+	}
+	first.Data = data
+	return first, nil
+}
+
+// transformEncoded decodes the image file of in, runs its pipeline over it
+// and encodes the result in the output format of in, the format of the file
+// when empty. The fraction of the filtering done is reported to progress,
+// which may be nil.
+func transformEncoded(ctx context.Context, in *pb.ImageChunk, progress func(float64)) (*pb.ImageResponse, error) {
+	p, outFormat := in.GetPipeline(), in.GetOutputFormat()
+	ops, err := filter.Select(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, span := tracing.Start(ctx, "decode", attribute.Int("image.bytes", len(in.GetData())))
+	img, format, err := codec.Decode(in.GetData(), in.GetFormat())
+	span.SetAttributes(attribute.String("image.format", format))
+	tracing.End(span, err)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot decode image: %v", err)
+	}
+	if outFormat == "" {
+		outFormat = format
+		if format == "webp" {
+			// WebP cannot be written, keep the image lossless.
+			outFormat = "png"
+		}
+	}
+
+	src := filter.FrameFromImage(img)
+	region, err := filter.RegionFromProto(p.GetRegion(), src.Width, src.Height)
+	if err != nil {
+		return nil, filter.Status(err)
+	}
+	fctx, span := tracing.Start(ctx, "filter",
+		attribute.String("filter.steps", stepNames(ctx, p)),
+		attribute.Int("image.width", src.Width),
+		attribute.Int("image.height", src.Height),
+	)
+	res, err := filter.ApplyContext(fctx, src, region, progress, tracePass(fctx), ops...)
+	tracing.End(span, err)
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return nil, status.FromContextError(err).Err()
+	}
+	if err != nil {
+		return nil, filter.Status(err)
+	}
+
+	_, span = tracing.Start(ctx, "encode", attribute.String("image.format", outFormat))
+	var buf bytes.Buffer
+	err = codec.Encode(&buf, res.Image(), outFormat, &codec.Options{Quality: int(in.GetQuality())})
+	span.SetAttributes(attribute.Int("image.bytes", buf.Len()))
+	tracing.End(span, err)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot encode image: %v", err)
+	}
+	return &pb.ImageResponse{
+		Image:  buf.Bytes(),
+		Format: codec.Normalize(outFormat),
+		Width:  int32(res.Width),
+		Height: int32(res.Height),
+	}, nil
+}
+
+// tracePass returns the hook tracing every pass of a pipeline over an image
+// within the span of ctx.
+func tracePass(ctx context.Context) filter.PassHook {
+	return func(pass int, op filter.Op, width, height int) func(error) {
+		_, pixelwise := op.(filter.Func)
+		_, span := tracing.Start(ctx, "filter.pass",
+			attribute.Int("filter.pass", pass),
+			attribute.Bool("filter.pixelwise", pixelwise),
+			attribute.Int("image.width", width),
+			attribute.Int("image.height", height),
+		)
+		return func(err error) { tracing.End(span, err) }
+	}
+}
+
+// stepNames returns the names of the filters of p, or of the filter selected
+// by the metadata of ctx when p is nil, separated by commas.
+func stepNames(ctx context.Context, p *pb.Pipeline) string {
+	steps := p.GetSteps()
+	if p == nil {
+		if step, err := filter.StepFromIncomingContext(ctx); err == nil {
+			steps = []*pb.Step{step}
+		}
+	}
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.GetFilter()
+	}
+	return strings.Join(names, ",")
+}
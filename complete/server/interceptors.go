@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"nichowil/grpc-tutorial/interceptor"
+	"nichowil/grpc-tutorial/metrics"
+	"nichowil/grpc-tutorial/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthPrefix starts the methods of the health service, which load
+// balancers call without credentials.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// interceptors returns the interceptors of the server as configured by c,
+// outermost first. Calls are recorded in r when metrics are served, and
+// traced with t unless it is nil.
+func (c *config) interceptors(r *metrics.Registry, t *tracing.Provider) (unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) {
+	if c.Metrics.Addr != "" {
+		m := interceptor.NewMetrics(r)
+		unary = append(unary, m.UnaryServerInterceptor())
+		stream = append(stream, m.StreamServerInterceptor())
+	}
+	if t != nil {
+		// before logging, which logs the trace of every call
+		tr := interceptor.NewTracing(t)
+		unary = append(unary, tr.UnaryServerInterceptor())
+		stream = append(stream, tr.StreamServerInterceptor())
+	}
+	if c.Interceptors.Logging {
+		l := interceptor.NewLogger(os.Stderr)
+		unary = append(unary, l.UnaryServerInterceptor())
+		stream = append(stream, l.StreamServerInterceptor())
+	}
+	if len(c.Auth.Tokens) > 0 {
+		a := newAuthenticator(c.Auth.Tokens)
+		unary = append(unary, a.unary)
+		stream = append(stream, a.stream)
+	}
+	if c.Interceptors.Timeout > 0 || c.Interceptors.StreamTimeout > 0 || len(c.Interceptors.MethodTimeouts) > 0 {
+		d := &interceptor.Deadlines{
+			Unary:   c.Interceptors.Timeout,
+			Stream:  c.Interceptors.StreamTimeout,
+			Methods: c.Interceptors.MethodTimeouts,
+		}
+		unary = append(unary, d.UnaryInterceptor())
+		stream = append(stream, d.StreamInterceptor())
+	}
+	return unary, stream
+}
+
+// authenticator rejects the calls that do not carry one of its tokens as a
+// bearer token with codes.Unauthenticated. Health checks are let through.
+type authenticator struct {
+	tokens [][]byte
+}
+
+func newAuthenticator(tokens []string) *authenticator {
+	a := &authenticator{}
+	for _, t := range tokens {
+		a.tokens = append(a.tokens, []byte(t))
+	}
+	return a
+}
+
+func (a *authenticator) check(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, healthPrefix) {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token := []byte(strings.TrimPrefix(values[0], "Bearer "))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(token, t) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
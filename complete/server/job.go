@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/job"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/status"
+)
+
+// resultChunkSize is the size of the chunks of a result sent by FetchResult.
+const resultChunkSize = 1 << 20
+
+func (s *server) SubmitJob(stream pb.Transform_SubmitJobServer) error {
+	in, err := receiveChunks(stream)
+	if err != nil {
+		return err
+	}
+
+	// The metadata of the stream is gone once the job runs, the filter it
+	// selects is kept as a pipeline instead.
+	ctx := stream.Context()
+	if in.Pipeline == nil {
+		step, err := filter.StepFromIncomingContext(ctx)
+		if err != nil {
+			return err
+		}
+		in.Pipeline = &pb.Pipeline{Steps: []*pb.Step{step}}
+	}
+	if _, err := filter.Select(ctx, in.Pipeline); err != nil {
+		return err
+	}
+
+	res, err := s.jobs.Submit(in)
+	if err != nil {
+		return job.Status(err)
+	}
+	return stream.SendAndClose(res)
+}
+
+// runJob transforms the input of a job, from the cache when possible.
+func (s *server) runJob(ctx context.Context, in *pb.ImageChunk, progress func(float64)) (*pb.ImageResponse, error) {
+	res, _, err := s.transformCached(ctx, in, progress)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.image("SubmitJob", in, res)
+	return res, nil
+}
+
+func (s *server) GetJob(ctx context.Context, in *pb.JobRequest) (*pb.Job, error) {
+	res, err := s.jobs.Get(in.GetId())
+	if err != nil {
+		return nil, job.Status(err)
+	}
+	return res, nil
+}
+
+func (s *server) WatchJob(in *pb.JobRequest, stream pb.Transform_WatchJobServer) error {
+	err := s.jobs.Watch(stream.Context(), in.GetId(), stream.Send)
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	if err == job.ErrNotFound {
+		return job.Status(err)
+	}
+	return err
+}
+
+func (s *server) CancelJob(ctx context.Context, in *pb.JobRequest) (*pb.Job, error) {
+	res, err := s.jobs.Cancel(in.GetId())
+	if err != nil {
+		return nil, job.Status(err)
+	}
+	return res, nil
+}
+
+func (s *server) FetchResult(in *pb.JobRequest, stream pb.Transform_FetchResultServer) error {
+	res, err := s.jobs.Result(in.GetId())
+	if err != nil {
+		return job.Status(err)
+	}
+	data := res.Image
+	for off := 0; off == 0 || off < len(data); off += resultChunkSize {
+		end := off + resultChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := &pb.ImageChunk{Data: data[off:end]}
+		if off == 0 {
+			chunk.Format = res.Format
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
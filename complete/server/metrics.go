@@ -0,0 +1,46 @@
+package main
+
+import (
+	"nichowil/grpc-tutorial/metrics"
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// transformMetrics count the images going through the transform methods,
+// by method and direction, "in" or "out". Streams count their pixels and
+// RGBA bytes both ways. Whole images count their files both ways but their
+// pixels on the way out only, as the files received are not decoded when
+// their result is cached.
+type transformMetrics struct {
+	pixels, bytes *metrics.Counter
+}
+
+func newTransformMetrics(r *metrics.Registry) *transformMetrics {
+	return &transformMetrics{
+		pixels: r.Counter("transform_pixels_total", "Number of pixels received and sent by the transform methods.", "method", "direction"),
+		bytes:  r.Counter("transform_bytes_total", "Bytes of the images received and sent by the transform methods, RGBA for streams and files otherwise.", "method", "direction"),
+	}
+}
+
+// flow counts the pixels and bytes going one way through a method.
+type flow struct {
+	pixels, bytes *metrics.CounterValue
+}
+
+func (m *transformMetrics) flow(method, direction string) flow {
+	return flow{m.pixels.With(method, direction), m.bytes.With(method, direction)}
+}
+
+// add counts n pixels of 4 bytes.
+func (f flow) add(n int) {
+	f.pixels.Add(float64(n))
+	f.bytes.Add(float64(4 * n))
+}
+
+// image counts the file of in received by method and the image res sent
+// back.
+func (m *transformMetrics) image(method string, in *pb.ImageChunk, res *pb.ImageResponse) {
+	m.bytes.With(method, "in").Add(float64(len(in.GetData())))
+	out := m.flow(method, "out")
+	out.pixels.Add(float64(res.GetWidth()) * float64(res.GetHeight()))
+	out.bytes.Add(float64(len(res.GetImage())))
+}
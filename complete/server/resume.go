@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"nichowil/grpc-tutorial/filter"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// transfers holds the resumable transfers by ID, at most max of them. A
+// transfer is forgotten once its result was sent, or once no stream was
+// attached to it for the grace period.
+type transfers struct {
+	grace time.Duration
+	max   int
+
+	mu   sync.Mutex
+	byID map[string]*transferState
+	// starting counts the transfers being started, not in byID yet
+	starting int
+}
+
+func newTransfers(grace time.Duration, max int) *transfers {
+	return &transfers{grace: grace, max: max, byID: make(map[string]*transferState)}
+}
+
+// transferState is the progress of a transfer. Its fields are only used by
+// the stream holding token, the others belong to the mutex of transfers.
+type transferState struct {
+	id     string
+	width  int // of the image
+	height int
+	window *filter.Window
+	out    *filter.Frame // rows of the result computed so far
+	ready  []bool
+	// rows counts the rows of the image received, sent the rows of the
+	// result sent on the current stream.
+	rows, sent int
+	token      chan struct{}
+
+	cancel context.CancelFunc // of the stream attached, nil when none is
+	stream int                // counts the streams attached
+	timer  *time.Timer        // forgets the transfer while no stream is attached
+	done   bool               // once the result was sent
+}
+
+// start creates a transfer running the pipeline of start over its image.
+// It fails with codes.ResourceExhausted while max transfers are kept.
+func (t *transfers) start(ctx context.Context, start *pb.TransferStart) (string, error) {
+	width, height := int(start.Width), int(start.Height)
+	if err := filter.CheckSize(width, height); err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	if start.Pipeline.GetRegion() != nil {
+		return "", status.Error(codes.InvalidArgument, "transfers do not support regions")
+	}
+	ops, err := filter.Select(ctx, start.Pipeline)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	if len(t.byID)+t.starting >= t.max {
+		t.mu.Unlock()
+		return "", status.Errorf(codes.ResourceExhausted, "the server already keeps %d transfers, try again later", t.max)
+	}
+	t.starting++
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.starting--
+		t.mu.Unlock()
+	}()
+
+	window, err := filter.NewWindow(width, height, ops...)
+	if err != nil {
+		return "", filter.Status(err)
+	}
+	id, err := newTransferID()
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+
+	st := &transferState{
+		id:     id,
+		width:  width,
+		height: height,
+		window: window,
+		out:    filter.NewFrame(window.Width(), window.Height()),
+		ready:  make([]bool, window.Height()),
+		token:  make(chan struct{}, 1),
+	}
+	t.mu.Lock()
+	t.byID[id] = st
+	t.mu.Unlock()
+	return id, nil
+}
+
+// attach attaches the stream of ctx to the transfer id, taking it over from
+// the stream it is attached to if any. The context returned is done once
+// another stream takes over. release detaches the stream, starting the
+// grace period.
+func (t *transfers) attach(ctx context.Context, id string) (st *transferState, _ context.Context, release func(), err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.mu.Lock()
+	st, ok := t.byID[id]
+	if !ok {
+		t.mu.Unlock()
+		cancel()
+		return nil, nil, nil, status.Errorf(codes.NotFound, "transfer %q not found, it may have expired", id)
+	}
+	if st.cancel != nil {
+		// the connection of that stream is most likely gone already
+		st.cancel()
+	}
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	st.cancel = cancel
+	st.stream++
+	stream := st.stream
+	t.mu.Unlock()
+
+	detach := func() {
+		cancel()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if st.stream == stream && !st.done {
+			st.cancel = nil
+			st.timer = time.AfterFunc(t.grace, func() { t.expire(st) })
+		}
+	}
+	select {
+	case st.token <- struct{}{}:
+	case <-ctx.Done():
+		detach()
+		return nil, nil, nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return st, ctx, func() {
+		detach()
+		<-st.token
+	}, nil
+}
+
+// finish forgets st once its result was sent, freeing its frames at once
+// instead of after the grace period.
+func (t *transfers) finish(st *transferState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st.done = true
+	if t.byID[st.id] == st {
+		delete(t.byID, st.id)
+	}
+}
+
+// expire forgets st unless a stream attached to it again.
+func (t *transfers) expire(st *transferState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.byID[st.id] == st && st.cancel == nil {
+		delete(t.byID, st.id)
+	}
+}
+
+func newTransferID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *server) Transfer(stream pb.Transform_TransferServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	start := req.GetStart()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, "the first message of a transfer must start or resume it")
+	}
+	id := start.Id
+	if id == "" {
+		if id, err = s.transfers.start(stream.Context(), start); err != nil {
+			return err
+		}
+	}
+	st, ctx, release, err := s.transfers.attach(stream.Context(), id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// rows sent after those the client received were lost with the
+	// previous stream
+	if start.Received < 0 || int(start.Received) > st.sent {
+		return status.Errorf(codes.InvalidArgument, "received %d rows but only %d were sent", start.Received, st.sent)
+	}
+	st.sent = int(start.Received)
+	in, out := s.metrics.flow("Transfer", "in"), s.metrics.flow("Transfer", "out")
+	if err := st.ack(stream); err != nil {
+		return err
+	}
+	if err := st.send(stream, out); err != nil {
+		return err
+	}
+
+	reqs := make(chan *pb.TransferRequest)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for st.sent < len(st.ready) {
+		select {
+		case <-ctx.Done():
+			if stream.Context().Err() == nil {
+				return status.Error(codes.Aborted, "the transfer was resumed on another stream")
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		case err := <-errc:
+			if err == io.EOF {
+				return status.Errorf(codes.FailedPrecondition, "the stream ended after %d of %d rows, resume the transfer to send the others", st.rows, st.height)
+			}
+			return err
+		case req := <-reqs:
+			if err := st.receive(req.GetRows()); err != nil {
+				return err
+			}
+			in.add(len(req.GetRows().Rgba) / 4)
+			if err := st.ack(stream); err != nil {
+				return err
+			}
+			if err := st.send(stream, out); err != nil {
+				return err
+			}
+		}
+	}
+	s.transfers.finish(st)
+	return nil
+}
+
+// receive stores the rows of tile that were not received yet and computes
+// the rows of the result that became ready.
+func (st *transferState) receive(tile *pb.Tile) error {
+	if tile == nil {
+		return status.Error(codes.InvalidArgument, "a transfer was already started on this stream")
+	}
+	if err := validateTile(tile); err != nil {
+		return err
+	}
+	y0 := int(tile.Origin.GetY())
+	if tile.Origin.GetX() != 0 || int(tile.Width) != st.width || y0 > st.rows || y0+int(tile.Height) > st.height {
+		return status.Errorf(codes.InvalidArgument, "expected whole rows of the %dx%d image from row %d, got a %dx%d tile at (%d, %d)",
+			st.width, st.height, st.rows, tile.Width, tile.Height, tile.Origin.GetX(), y0)
+	}
+	for y := st.rows; y < y0+int(tile.Height); y++ {
+		row := tile.Rgba[(y-y0)*st.width*4:]
+		for x := 0; x < st.width; x++ {
+			i := x * 4
+			c := filter.Color{R: float32(row[i]), G: float32(row[i+1]), B: float32(row[i+2]), A: float32(row[i+3])}
+			ready, err := st.window.Set(x, y, c)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			for _, r := range ready {
+				st.window.Row(r, st.out.Row(r))
+				st.ready[r] = true
+			}
+		}
+		st.rows++
+	}
+	return nil
+}
+
+func (st *transferState) ack(stream pb.Transform_TransferServer) error {
+	return stream.Send(&pb.TransferResponse{Message: &pb.TransferResponse_Ack{Ack: &pb.TransferAck{
+		Id:     st.id,
+		Rows:   int32(st.rows),
+		Width:  int32(st.out.Width),
+		Height: int32(st.out.Height),
+	}}})
+}
+
+// send sends the rows of the result that are ready after the last one sent,
+// as tiles of at most maxTileSize bytes, counting their pixels in out.
+func (st *transferState) send(stream pb.Transform_TransferServer, out flow) error {
+	limit := maxTileSize / (4 * st.out.Width)
+	if limit < 1 {
+		limit = 1
+	}
+	for st.sent < len(st.ready) && st.ready[st.sent] {
+		lo, hi := st.sent, st.sent
+		for hi < len(st.ready) && st.ready[hi] && hi-lo < limit {
+			hi++
+		}
+		tile := &pb.Tile{
+			Origin: &pb.Point{Y: int32(lo)},
+			Width:  int32(st.out.Width),
+			Height: int32(hi - lo),
+			Rgba:   make([]byte, st.out.Width*(hi-lo)*4),
+		}
+		for y := lo; y < hi; y++ {
+			filter.PackRGBA(tile.Rgba[(y-lo)*st.out.Width*4:], st.out.Row(y))
+		}
+		if err := stream.Send(&pb.TransferResponse{Message: &pb.TransferResponse_Rows{Rows: tile}}); err != nil {
+			return err
+		}
+		out.add(len(tile.Rgba) / 4)
+		st.sent = hi
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/metrics"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const transferWidth, transferHeight = 8, 6
+
+// transferPipeline reads neighbouring rows, so that the rows of the result
+// become ready after the rows of the image.
+var transferPipeline = &pb.Pipeline{Steps: []*pb.Step{{Filter: "blur", Params: map[string]float64{"radius": 1}}}}
+
+// newTransferClient returns a client of a server keeping at most max
+// transfers, each for grace once no stream is attached.
+func newTransferClient(t *testing.T, grace time.Duration, max int) pb.TransformClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	pb.RegisterTransformServer(s, &server{transfers: newTransfers(grace, max), metrics: newTransformMetrics(metrics.NewRegistry())})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewTransformClient(conn)
+}
+
+// transferImage is the image transferred, with the result of the pipeline
+// over it, packed.
+func transferImage(t *testing.T) (src, want []byte) {
+	t.Helper()
+	frame := filter.NewFrame(transferWidth, transferHeight)
+	for i := range frame.Pix {
+		frame.Pix[i] = filter.Color{R: float32(i % 256), G: float32(3 * i % 256), B: float32(7 * i % 256), A: 255}
+	}
+	blur, err := filter.New(filter.Spec{Name: "blur", Params: filter.Params{"radius": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := filter.Apply(frame, blur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, want = make([]byte, 4*len(frame.Pix)), make([]byte, 4*len(res.Pix))
+	filter.PackRGBA(src, frame.Pix)
+	filter.PackRGBA(want, res.Pix)
+	return src, want
+}
+
+// transferStream is a Transfer stream with the rows of the result it
+// received.
+type transferStream struct {
+	pb.Transform_TransferClient
+	cancel context.CancelFunc
+	id     string
+	rows   []byte
+}
+
+// openTransfer starts a transfer, or resumes the one of id with the first
+// received rows of the result already received, and reads its first
+// acknowledgement.
+func openTransfer(t *testing.T, client pb.TransformClient, id string, received int) (*transferStream, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Transfer(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	ts := &transferStream{Transform_TransferClient: stream, cancel: cancel}
+	t.Cleanup(cancel)
+	start := &pb.TransferStart{Id: id, Received: int32(received)}
+	if id == "" {
+		start.Width, start.Height, start.Pipeline = transferWidth, transferHeight, transferPipeline
+	}
+	if err := stream.Send(&pb.TransferRequest{Message: &pb.TransferRequest_Start{Start: start}}); err != nil {
+		return nil, err
+	}
+	ack, err := ts.ack()
+	if err != nil {
+		return nil, err
+	}
+	ts.id = ack.Id
+	return ts, nil
+}
+
+// ack reads the messages of the stream up to the next acknowledgement,
+// keeping the rows of the result.
+func (ts *transferStream) ack() (*pb.TransferAck, error) {
+	for {
+		res, err := ts.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if ack := res.GetAck(); ack != nil {
+			return ack, nil
+		}
+		ts.rows = append(ts.rows, res.GetRows().Rgba...)
+	}
+}
+
+// readRows reads the messages of the stream until it received n rows of
+// the result in all.
+func (ts *transferStream) readRows(n int) error {
+	for len(ts.rows) < n*4*transferWidth {
+		res, err := ts.Recv()
+		if err != nil {
+			return err
+		}
+		ts.rows = append(ts.rows, res.GetRows().Rgba...)
+	}
+	return nil
+}
+
+// end reads the messages of the stream until it ends, returning nil when it
+// ended without error.
+func (ts *transferStream) end() error {
+	for {
+		res, err := ts.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ts.rows = append(ts.rows, res.GetRows().Rgba...)
+	}
+}
+
+// sendRows sends rows [lo, hi) of src and reads their acknowledgement.
+func (ts *transferStream) sendRows(src []byte, lo, hi int) (*pb.TransferAck, error) {
+	err := ts.Send(&pb.TransferRequest{Message: &pb.TransferRequest_Rows{Rows: &pb.Tile{
+		Origin: &pb.Point{Y: int32(lo)},
+		Width:  transferWidth,
+		Height: int32(hi - lo),
+		Rgba:   src[lo*transferWidth*4 : hi*transferWidth*4],
+	}}})
+	if err != nil {
+		return nil, err
+	}
+	return ts.ack()
+}
+
+func checkCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Fatalf("got %v, want code %v", err, code)
+	}
+}
+
+// TestTransferTakeOver resumes a transfer on a second stream, which aborts
+// the first one, and checks the result the two streams received.
+func TestTransferTakeOver(t *testing.T) {
+	client := newTransferClient(t, time.Minute, 4)
+	src, want := transferImage(t)
+
+	first, err := openTransfer(t, client, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := first.sendRows(src, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.Rows != 3 || ack.Width != transferWidth || ack.Height != transferHeight {
+		t.Fatalf("got acknowledgement %v, want 3 rows of a %dx%d result", ack, transferWidth, transferHeight)
+	}
+	// the last row sent is read by the next row of the result
+	const received = 2
+	if err := first.readRows(received); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := openTransfer(t, client, first.id, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = first.Recv()
+	checkCode(t, err, codes.Aborted)
+
+	if _, err := second.sendRows(src, 3, transferHeight); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.end(); err != nil {
+		t.Fatalf("the stream ended with %v once the result was sent", err)
+	}
+	if got := append(first.rows, second.rows...); !bytes.Equal(got, want) {
+		t.Errorf("got result %v, want %v", got, want)
+	}
+
+	// a transfer is forgotten once its result was sent
+	_, err = openTransfer(t, client, first.id, transferHeight)
+	checkCode(t, err, codes.NotFound)
+}
+
+func TestTransferExpires(t *testing.T) {
+	const grace = 200 * time.Millisecond
+	client := newTransferClient(t, grace, 4)
+	src, _ := transferImage(t)
+
+	ts, err := openTransfer(t, client, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.sendRows(src, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	ts.cancel()
+
+	// resuming within the grace period continues with the rows held
+	resumed, err := openTransfer(t, client, ts.id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack, err := resumed.sendRows(src, 1, 2); err != nil || ack.Rows != 2 {
+		t.Fatalf("got acknowledgement %v and error %v, want 2 rows", ack, err)
+	}
+	resumed.cancel()
+
+	time.Sleep(4 * grace)
+	_, err = openTransfer(t, client, ts.id, 0)
+	checkCode(t, err, codes.NotFound)
+}
+
+func TestTransferReceivedTooMany(t *testing.T) {
+	client := newTransferClient(t, time.Minute, 4)
+	src, _ := transferImage(t)
+
+	ts, err := openTransfer(t, client, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.sendRows(src, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.readRows(2); err != nil {
+		t.Fatal(err)
+	}
+	ts.cancel()
+	// 2 rows of the result were sent
+	_, err = openTransfer(t, client, ts.id, 3)
+	checkCode(t, err, codes.InvalidArgument)
+	_, err = openTransfer(t, client, ts.id, -1)
+	checkCode(t, err, codes.InvalidArgument)
+	if _, err := openTransfer(t, client, ts.id, 2); err != nil {
+		t.Fatalf("resuming with every row sent received failed: %v", err)
+	}
+}
+
+func TestTransferLimit(t *testing.T) {
+	const max = 2
+	client := newTransferClient(t, time.Minute, max)
+	src, _ := transferImage(t)
+
+	var streams []*transferStream
+	for i := 0; i < max; i++ {
+		ts, err := openTransfer(t, client, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, ts)
+	}
+	_, err := openTransfer(t, client, "", 0)
+	checkCode(t, err, codes.ResourceExhausted)
+
+	// a transfer whose result was sent frees its slot
+	if _, err := streams[0].sendRows(src, 0, transferHeight); err != nil {
+		t.Fatal(err)
+	}
+	if err := streams[0].end(); err != nil {
+		t.Fatalf("the stream ended with %v once the result was sent", err)
+	}
+	if _, err := openTransfer(t, client, "", 0); err != nil {
+		t.Fatalf("starting a transfer once another one ended failed: %v", err)
+	}
+}
@@ -4,21 +4,38 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"nichowil/grpc-tutorial/cache"
+	"nichowil/grpc-tutorial/job"
+	"nichowil/grpc-tutorial/metrics"
+	"nichowil/grpc-tutorial/tracing"
 	pb "nichowil/grpc-tutorial/transform"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
-var (
-	port = flag.Int("port", 50051, "The server port")
-)
+var configPath = flag.String("config", "", "YAML file configuring the server, overridden by $TRANSFORM_* variables and by the flags set on the command line")
 
 type server struct {
 	pb.UnimplementedTransformServer
+	jobs  *job.Manager
+	cache *cache.Cache // nil when disabled
+	// streamWorkers is the number of goroutines of a Transform stream.
+	streamWorkers int
+	transfers     *transfers
+	metrics       *transformMetrics
 }
 
 // SayHello implements helloworld.TransformServer
@@ -27,33 +44,124 @@ func (s *server) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloRe
 	return &pb.HelloResponse{Message: "Hello " + in.GetName()}, nil
 }
 
-func (s *server) Transform(stream pb.Transform_TransformServer) error {
-	for {
-		pixel, err := stream.Recv()
-		if err == io.EOF {
-			return nil
+// SimulateError returns the error named by the message of in, for clients
+// to see how errors look: "invalid argument", "detail" for one carrying
+// details, and "timeout" for a call taking two seconds.
+func (s *server) SimulateError(ctx context.Context, in *pb.ErrorHandlingRequest) (*pb.ErrorHandlingResponse, error) {
+	switch in.GetMessage() {
+	case "invalid argument":
+		return nil, status.Error(codes.InvalidArgument, "Max num of characters exceed")
+	case "timeout":
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
 		}
+	case "detail":
+		st, err := status.New(codes.InvalidArgument, "invalid username").WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+				Field:       "message",
+				Description: "The message must only contain alphanumeric characters",
+			}},
+		})
 		if err != nil {
-			return err
+			return nil, status.Error(codes.Internal, err.Error())
 		}
-
-		// do smth to pixel here
-		pixel.Color.R = 0
-
-		stream.Send(pixel)
+		return nil, st.Err()
 	}
+	return &pb.ErrorHandlingResponse{Message: "Testing error code : " + in.GetMessage()}, nil
 }
 
 func main() {
+	cfg := defaultConfig()
+	cfg.bindFlags(flag.CommandLine)
 	flag.Parse()
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err := cfg.load(*configPath, os.LookupEnv); err != nil {
+		log.Fatalf("failed to load configuration: %v", err)
+	}
+	// flags set on the command line override the file and the environment
+	flag.Parse()
+	if err := cfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+	creds, err := cfg.credentials()
+	if err != nil {
+		log.Fatalf("failed to set up TLS: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer()
-	pb.RegisterTransformServer(s, &server{})
+	registry := metrics.NewRegistry()
+	srv := &server{streamWorkers: cfg.StreamWorkers, transfers: newTransfers(cfg.TransferGrace, cfg.MaxTransfers), metrics: newTransformMetrics(registry)}
+	if cfg.Cache.Size > 0 {
+		if srv.cache, err = cache.New(cfg.Cache.Size, cfg.Cache.Dir, cfg.Cache.DiskSize); err != nil {
+			log.Fatalf("failed to open cache: %v", err)
+		}
+	}
+
+	var store job.Store
+	if cfg.JobDB != "" {
+		if store, err = job.OpenBolt(cfg.JobDB); err != nil {
+			log.Fatalf("failed to open job database: %v", err)
+		}
+		defer store.Close()
+	}
+	srv.jobs, err = job.NewManager(cfg.Workers, cfg.Queue, cfg.JobRetention, store, srv.runJob)
+	if err != nil {
+		log.Fatalf("failed to resume jobs: %v", err)
+	}
+	defer srv.jobs.Close()
+
+	var tracer *tracing.Provider
+	if cfg.Tracing.Exporter != "" {
+		if tracer, err = tracing.NewProvider(cfg.Tracing.Service, cfg.Tracing.Exporter); err != nil {
+			log.Fatalf("failed to set up tracing: %v", err)
+		}
+		defer tracer.Close()
+	}
+
+	unary, stream := cfg.interceptors(registry, tracer)
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterTransformServer(s, srv)
+	if cfg.Health {
+		h := health.NewServer()
+		h.SetServingStatus(pb.Transform_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+		healthpb.RegisterHealthServer(s, h)
+	}
+	if cfg.Reflection {
+		reflection.Register(s)
+	}
+	if cfg.Metrics.Addr != "" {
+		ms, err := metrics.Serve(cfg.Metrics.Addr, registry)
+		if err != nil {
+			log.Fatalf("failed to serve metrics: %v", err)
+		}
+		defer ms.Close()
+		log.Printf("metrics served at http://%v/metrics", ms.Addr)
+	}
+
+	// stopping lets the calls in progress end, for a while, then closes
+	// what the server holds
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Printf("stopping once the calls in progress end, at most %v", shutdownGrace)
+		time.AfterFunc(shutdownGrace, s.Stop)
+		s.GracefulStop()
+	}()
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+// shutdownGrace bounds how long a stopping server waits for the calls in
+// progress.
+const shutdownGrace = 10 * time.Second
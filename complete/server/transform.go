@@ -0,0 +1,688 @@
+package main
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/integrity"
+	"nichowil/grpc-tutorial/pool"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (s *server) Transform(stream pb.Transform_TransformServer) error {
+	var (
+		t   *transformer
+		sum summary
+	)
+	defer func() {
+		if t != nil {
+			t.pool.Stop()
+		}
+	}()
+
+	send := func(pixels ...*pb.Pixel) func() error {
+		return func() error {
+			for _, pixel := range pixels {
+				t.cache.sentPixel(pixel)
+				sum.out.AddPixel(pixel)
+				t.sentPixels(1)
+				if err := stream.Send(pixel); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	sendRows := func(rows []int) error {
+		for _, y := range rows {
+			y := y
+			err := t.pool.Submit(func() func() error {
+				row := make([]filter.Color, t.window.Width())
+				x0, y0 := t.row(y, row)
+				pixels := make([]*pb.Pixel, len(row))
+				for x, c := range row {
+					pixels[x] = &pb.Pixel{Color: c.Proto(), Point: &pb.Point{X: int32(x0 + x), Y: int32(y0)}}
+				}
+				return send(pixels...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// finish sends the rows that were not sent yet once every pixel was
+	// received, or the cached result
+	finish := func() error {
+		if t == nil {
+			return nil
+		}
+		if t.cache.hit() {
+			// progress may still be on its way
+			if err := t.pool.Wait(); err != nil {
+				return err
+			}
+			if err := t.cache.finish(stream); err != nil {
+				return err
+			}
+			return send(tilePixels(t.cache.result)...)()
+		}
+		if t.window != nil {
+			if err := sendRows(t.window.Flush()); err != nil {
+				return err
+			}
+		}
+		if err := t.pool.Wait(); err != nil {
+			return err
+		}
+		return t.cache.finish(stream)
+	}
+
+	for {
+		pixel, err := stream.Recv()
+		if err == io.EOF {
+			if err := sum.check(); err != nil {
+				return err
+			}
+			if err := finish(); err != nil {
+				return err
+			}
+			if reply := sum.reply(); reply != nil {
+				return stream.Send(&pb.Pixel{Summary: reply})
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sum.receive(pixel.Summary); err != nil {
+			return err
+		}
+		if pixel.Summary != nil {
+			continue
+		}
+		if pixel.GetColor() == nil {
+			return status.Error(codes.InvalidArgument, "pixel color is missing")
+		}
+		if t == nil {
+			if t, err = s.setup(stream, pixel.Pipeline); err != nil {
+				return err
+			}
+		}
+		sum.in.AddPixel(pixel)
+		t.cache.receivedPixel(pixel)
+		if t.received(1) {
+			p := t.progress()
+			if err := t.pool.Submit(func() func() error {
+				return func() error {
+					p.Sent = t.sent
+					return stream.Send(&pb.Pixel{Progress: p})
+				}
+			}); err != nil {
+				return err
+			}
+		}
+		if t.cache.hit() {
+			continue
+		}
+
+		x, y := int(pixel.Point.GetX()), int(pixel.Point.GetY())
+		if !t.region.Contains(x, y) {
+			// outside of the region, sent back unchanged
+			if err := t.pool.Submit(func() func() error { return send(pixel) }); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t.apply != nil {
+			err := t.pool.Submit(func() func() error {
+				pixel.Color = t.pixel(x, y, filter.ColorFromProto(pixel.Color)).Proto()
+				return send(pixel)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		x, y = t.local(x, y)
+		ready, err := t.window.Set(x, y, filter.ColorFromProto(pixel.Color))
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err := sendRows(ready); err != nil {
+			return err
+		}
+	}
+}
+
+// tilePixels returns the pixels of tile.
+func tilePixels(tile *pb.Tile) []*pb.Pixel {
+	res := make([]*pb.Pixel, 0, len(tile.Rgba)/4)
+	for i := 0; i+4 <= len(tile.Rgba); i += 4 {
+		p := i / 4
+		c := filter.Color{R: float32(tile.Rgba[i]), G: float32(tile.Rgba[i+1]), B: float32(tile.Rgba[i+2]), A: float32(tile.Rgba[i+3])}
+		res = append(res, &pb.Pixel{
+			Color: c.Proto(),
+			Point: &pb.Point{X: tile.Origin.GetX() + int32(p)%tile.Width, Y: tile.Origin.GetY() + int32(p)/tile.Width},
+		})
+	}
+	return res
+}
+
+// maxTileSize bounds the RGBA bytes of a tile sent back by TransformTiles,
+// well below the default message size limit of a client.
+const maxTileSize = 1 << 20
+
+func (s *server) TransformTiles(stream pb.Transform_TransformTilesServer) error {
+	var (
+		t   *transformer
+		sum summary
+	)
+	defer func() {
+		if t != nil {
+			t.pool.Stop()
+		}
+	}()
+
+	send := func(tile *pb.Tile) func() error {
+		return func() error {
+			t.cache.sentTile(tile)
+			sum.out.AddTile(tile)
+			t.sentPixels(len(tile.Rgba) / 4)
+			return stream.Send(tile)
+		}
+	}
+
+	sendRows := func(rows []int) error {
+		// rows are sent as tiles of at most maxTileSize bytes, a rotated
+		// image may complete all of its rows at once
+		width := t.window.Width()
+		limit := maxTileSize / (4 * width)
+		if limit < 1 {
+			limit = 1
+		}
+		for _, run := range rowRuns(rows) {
+			for lo := run[0]; lo < run[1]; lo += limit {
+				lo, hi := lo, lo+limit
+				if hi > run[1] {
+					hi = run[1]
+				}
+				err := t.pool.Submit(func() func() error {
+					tile := &pb.Tile{
+						Width:  int32(width),
+						Height: int32(hi - lo),
+						Rgba:   make([]byte, width*(hi-lo)*4),
+					}
+					row := make([]filter.Color, width)
+					for y := lo; y < hi; y++ {
+						x0, y0 := t.row(y, row)
+						if y == lo {
+							tile.Origin = &pb.Point{X: int32(x0), Y: int32(y0)}
+						}
+						filter.PackRGBA(tile.Rgba[(y-lo)*width*4:], row)
+					}
+					return send(tile)
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// finish sends the rows that were not sent yet once every pixel was
+	// received, or the cached result
+	finish := func() error {
+		if t == nil {
+			return nil
+		}
+		if t.cache.hit() {
+			// progress may still be on its way
+			if err := t.pool.Wait(); err != nil {
+				return err
+			}
+			if err := t.cache.finish(stream); err != nil {
+				return err
+			}
+			for _, part := range splitTile(t.cache.result) {
+				if err := send(part)(); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if t.window != nil {
+			if err := sendRows(t.window.Flush()); err != nil {
+				return err
+			}
+		}
+		if err := t.pool.Wait(); err != nil {
+			return err
+		}
+		return t.cache.finish(stream)
+	}
+
+	for {
+		tile, err := stream.Recv()
+		if err == io.EOF {
+			if err := sum.check(); err != nil {
+				return err
+			}
+			if err := finish(); err != nil {
+				return err
+			}
+			if reply := sum.reply(); reply != nil {
+				return stream.Send(&pb.Tile{Summary: reply})
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sum.receive(tile.Summary); err != nil {
+			return err
+		}
+		if tile.Summary != nil {
+			continue
+		}
+		if err := validateTile(tile); err != nil {
+			return err
+		}
+		if t == nil {
+			if t, err = s.setup(stream, tile.Pipeline); err != nil {
+				return err
+			}
+		}
+		sum.in.AddTile(tile)
+		t.cache.receivedTile(tile)
+		if t.received(len(tile.Rgba) / 4) {
+			p := t.progress()
+			if err := t.pool.Submit(func() func() error {
+				return func() error {
+					p.Sent = t.sent
+					return stream.Send(&pb.Tile{Progress: p})
+				}
+			}); err != nil {
+				return err
+			}
+		}
+		if t.cache.hit() {
+			continue
+		}
+
+		if t.apply != nil {
+			err := t.pool.Submit(func() func() error {
+				t.tile(tile)
+				return send(tile)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var ready []int
+		for i := 0; i < len(tile.Rgba); i += 4 {
+			p := i / 4
+			x := int(tile.Origin.X) + p%int(tile.Width)
+			y := int(tile.Origin.Y) + p/int(tile.Width)
+			if !t.region.Contains(x, y) {
+				continue
+			}
+			x, y = t.local(x, y)
+			c := filter.Color{R: float32(tile.Rgba[i]), G: float32(tile.Rgba[i+1]), B: float32(tile.Rgba[i+2]), A: float32(tile.Rgba[i+3])}
+			rows, err := t.window.Set(x, y, c)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			ready = append(ready, rows...)
+		}
+		// the pixels outside of the region are sent back unchanged, the
+		// ones inside once their rows are filtered
+		for _, part := range outsideRegion(tile, t.region) {
+			part := part
+			if err := t.pool.Submit(func() func() error { return send(part) }); err != nil {
+				return err
+			}
+		}
+		if err := sendRows(ready); err != nil {
+			return err
+		}
+	}
+}
+
+// outsideRegion returns the parts of tile outside of r, up to four tiles
+// above, below, left and right of it, none when r is nil.
+func outsideRegion(tile *pb.Tile, r *filter.Region) []*pb.Tile {
+	if r == nil {
+		return nil
+	}
+	x0, y0 := int(tile.Origin.X), int(tile.Origin.Y)
+	x1, y1 := x0+int(tile.Width), y0+int(tile.Height)
+	// the part of the tile in the region
+	ix0, iy0 := maxInt(x0, r.X), maxInt(y0, r.Y)
+	ix1, iy1 := minInt(x1, r.X+r.Width), minInt(y1, r.Y+r.Height)
+	if ix0 >= ix1 || iy0 >= iy1 {
+		return []*pb.Tile{tile}
+	}
+	var res []*pb.Tile
+	for _, part := range [][4]int{
+		{x0, y0, x1, iy0},
+		{x0, iy1, x1, y1},
+		{x0, iy0, ix0, iy1},
+		{ix1, iy0, x1, iy1},
+	} {
+		if part[0] < part[2] && part[1] < part[3] {
+			res = append(res, subTile(tile, part[0], part[1], part[2], part[3]))
+		}
+	}
+	return res
+}
+
+// subTile copies the part [x0, x1) x [y0, y1) of tile, in image
+// coordinates, into a tile of its own.
+func subTile(tile *pb.Tile, x0, y0, x1, y1 int) *pb.Tile {
+	res := &pb.Tile{
+		Origin: &pb.Point{X: int32(x0), Y: int32(y0)},
+		Width:  int32(x1 - x0),
+		Height: int32(y1 - y0),
+		Rgba:   make([]byte, 0, (x1-x0)*(y1-y0)*4),
+	}
+	stride := 4 * int(tile.Width)
+	left := 4 * (x0 - int(tile.Origin.X))
+	for y := y0; y < y1; y++ {
+		row := (y - int(tile.Origin.Y)) * stride
+		res.Rgba = append(res.Rgba, tile.Rgba[row+left:row+left+4*(x1-x0)]...)
+	}
+	return res
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// splitTile splits tile into tiles of at most maxTileSize bytes.
+func splitTile(tile *pb.Tile) (res []*pb.Tile) {
+	rowSize := 4 * int(tile.Width)
+	limit := maxTileSize / rowSize
+	if limit < 1 {
+		limit = 1
+	}
+	for lo := 0; lo < int(tile.Height); lo += limit {
+		hi := lo + limit
+		if hi > int(tile.Height) {
+			hi = int(tile.Height)
+		}
+		res = append(res, &pb.Tile{
+			Origin: &pb.Point{X: tile.Origin.GetX(), Y: tile.Origin.GetY() + int32(lo)},
+			Width:  tile.Width,
+			Height: int32(hi - lo),
+			Rgba:   tile.Rgba[lo*rowSize : hi*rowSize],
+		})
+	}
+	return res
+}
+
+// summary tallies the pixels of a stream for the summary a client may end
+// it with, see pb.Summary.
+type summary struct {
+	in, out integrity.Tally
+	client  *pb.Summary
+}
+
+// receive records the summary carried by a message, which must be the last
+// one of the stream.
+func (s *summary) receive(m *pb.Summary) error {
+	if s.client != nil {
+		return status.Error(codes.InvalidArgument, "the summary must be the last message of the stream")
+	}
+	s.client = m
+	return nil
+}
+
+// check compares the pixels received to the summary of the client.
+func (s *summary) check() error {
+	if s.client == nil {
+		return nil
+	}
+	return integrity.Compare("pixels sent by the client", integrity.FromProto(s.client.Input), s.in)
+}
+
+// reply returns the summary sent back to a client that sent one, nil
+// otherwise.
+func (s *summary) reply() *pb.Summary {
+	if s.client == nil {
+		return nil
+	}
+	return &pb.Summary{Input: s.in.Proto(), Output: s.out.Proto()}
+}
+
+// transformer runs the pipeline of a stream over the pixels it receives.
+type transformer struct {
+	// apply is set when every step works pixel by pixel, window otherwise.
+	apply  filter.Func
+	window *filter.Window
+	// region limits the pipeline when set, the rows of window are then the
+	// rows of the region.
+	region *filter.Region
+	// cache is nil unless the result of the stream can be cached.
+	cache *streamCache
+	// pool computes the results and sends them back.
+	pool *pool.Pool
+
+	// interval is how often progress is reported, never when 0.
+	interval time.Duration
+	reported time.Time
+	// pixels received and sent, the latter counted by the goroutine
+	// sending, out of total when the size of the image is known
+	in, sent, total int64
+	// inFlow and outFlow count them in the metrics of the method
+	inFlow, outFlow flow
+}
+
+// received counts n more pixels received and reports whether progress is
+// due.
+func (t *transformer) received(n int) bool {
+	t.in += int64(n)
+	t.inFlow.add(n)
+	if t.interval == 0 || time.Since(t.reported) < t.interval {
+		return false
+	}
+	t.reported = time.Now()
+	return true
+}
+
+// sentPixels counts n more pixels sent.
+func (t *transformer) sentPixels(n int) {
+	t.sent += int64(n)
+	t.outFlow.add(n)
+}
+
+// progress returns the progress of the stream, without the pixels sent.
+func (t *transformer) progress() *pb.Progress {
+	return &pb.Progress{Received: t.in, Total: t.total}
+}
+
+// local converts the position of a pixel of the image to its position in
+// the region.
+func (t *transformer) local(x, y int) (int, int) {
+	if t.region == nil {
+		return x, y
+	}
+	return x - t.region.X, y - t.region.Y
+}
+
+// pixel filters the color c of the pixel at (x, y) of the image, which must
+// be in the region.
+func (t *transformer) pixel(x, y int, c filter.Color) filter.Color {
+	lx, ly := t.local(x, y)
+	return t.region.Blend(lx, ly, c, t.apply(c))
+}
+
+// tile filters the pixels of tile in the region, in place.
+func (t *transformer) tile(tile *pb.Tile) {
+	if t.region == nil {
+		filter.ApplyRGBA(t.apply, tile.Rgba)
+		return
+	}
+	for i := 0; i < len(tile.Rgba); i += 4 {
+		p := i / 4
+		x := int(tile.Origin.X) + p%int(tile.Width)
+		y := int(tile.Origin.Y) + p/int(tile.Width)
+		if !t.region.Contains(x, y) {
+			continue
+		}
+		c := filter.Color{R: float32(tile.Rgba[i]), G: float32(tile.Rgba[i+1]), B: float32(tile.Rgba[i+2]), A: float32(tile.Rgba[i+3])}
+		filter.PackRGBA(tile.Rgba[i:i+4], []filter.Color{t.pixel(x, y, c)})
+	}
+}
+
+// row computes output row y of the window into dst and returns the
+// position of its first pixel in the image.
+func (t *transformer) row(y int, dst []filter.Color) (int, int) {
+	t.window.Row(y, dst)
+	if t.region == nil {
+		return 0, y
+	}
+	src := t.window.Source(y)
+	for x := range dst {
+		dst[x] = t.region.Blend(x, y, src[x], dst[x])
+	}
+	return t.region.X, t.region.Y + y
+}
+
+// setup prepares the filters a stream asked for with the pipeline of its
+// first message. Filters working pixel by pixel are applied as a single
+// Func, any other pipeline through a window over the image announced in the
+// metadata of the stream, or over the region of the pipeline. The size of
+// the image sent back is announced in the response header whenever it is
+// known. Results are computed by streamWorkers goroutines and sent back as
+// they are ready, unless the client asked for them in order.
+func (s *server) setup(stream grpc.ServerStream, p *pb.Pipeline) (*transformer, error) {
+	ctx := stream.Context()
+	ops, err := filter.Select(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := filter.ImageSizeFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := filter.OrderedFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := filter.ProgressIntervalFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	region, err := filter.RegionFromProto(p.GetRegion(), width, height)
+	if err != nil {
+		return nil, filter.Status(err)
+	}
+	method := "unknown"
+	if m, ok := grpc.MethodFromServerStream(stream); ok {
+		method = m[strings.LastIndex(m, "/")+1:]
+	}
+	t := &transformer{
+		region:   region,
+		interval: interval,
+		reported: time.Now(),
+		total:    int64(width) * int64(height),
+		inFlow:   s.metrics.flow(method, "in"),
+		outFlow:  s.metrics.flow(method, "out"),
+	}
+
+	outWidth, outHeight := width, height
+	// windows fuse the steps themselves, once they checked them, so that
+	// errors name the step of the pipeline; steps all working pixel by
+	// pixel keep the size of the image and run as one
+	fused := filter.Fuse(ops)
+	switch apply, ok := fused[0].(filter.Func); {
+	case ok && len(fused) == 1:
+		t.apply = apply
+	case region != nil:
+		if t.window, err = filter.NewRegionWindow(region, ops...); err != nil {
+			return nil, filter.Status(err)
+		}
+	case width == 0:
+		return nil, status.Errorf(codes.InvalidArgument, "filters reading neighbouring pixels need the %s and %s metadata, or a region", filter.MetadataWidth, filter.MetadataHeight)
+	default:
+		if t.window, err = filter.NewWindow(width, height, ops...); err != nil {
+			return nil, filter.Status(err)
+		}
+		outWidth, outHeight = t.window.Width(), t.window.Height()
+	}
+
+	if width != 0 {
+		if err := sendSize(stream, outWidth, outHeight); err != nil {
+			return nil, err
+		}
+	}
+	// clients send only the pixels of a region, which the digest of their
+	// image cannot be checked against
+	if region == nil {
+		if t.cache, err = s.newStreamCache(ctx, p, width, height, outWidth, outHeight); err != nil {
+			return nil, err
+		}
+	}
+
+	t.pool = pool.New(s.streamWorkers, ordered)
+	return t, nil
+}
+
+// sendSize announces the size of the image sent back in the response header.
+func sendSize(stream grpc.ServerStream, width, height int) error {
+	return stream.SendHeader(metadata.Pairs(
+		filter.MetadataOutputWidth, strconv.Itoa(width),
+		filter.MetadataOutputHeight, strconv.Itoa(height),
+	))
+}
+
+// rowRuns groups increasing row numbers into runs of consecutive rows, each
+// given as [first, last+1).
+func rowRuns(rows []int) (res [][2]int) {
+	for _, y := range rows {
+		if n := len(res); n > 0 && res[n-1][1] == y {
+			res[n-1][1]++
+			continue
+		}
+		res = append(res, [2]int{y, y + 1})
+	}
+	return
+}
+
+func validateTile(tile *pb.Tile) error {
+	if tile.GetOrigin() == nil {
+		return status.Error(codes.InvalidArgument, "tile origin is missing")
+	}
+	if tile.Width <= 0 || tile.Height <= 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid tile size %dx%d", tile.Width, tile.Height)
+	}
+	if want := int(tile.Width) * int(tile.Height) * 4; len(tile.Rgba) != want {
+		return status.Errorf(codes.InvalidArgument, "tile %dx%d needs %d bytes of RGBA, got %d", tile.Width, tile.Height, want, len(tile.Rgba))
+	}
+	return nil
+}
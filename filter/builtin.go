@@ -0,0 +1,315 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultName is the filter applied when a client does not ask for one. It
+// keeps the original behaviour of the server, which zeroes the red channel.
+const DefaultName = "zero"
+
+func init() {
+	Register(Definition{
+		Name: "grayscale",
+		New: func(s Spec) (Op, error) {
+			return Func(func(c Color) Color {
+				l := luma(c)
+				return Color{l, l, l, c.A}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name: "invert",
+		New: func(s Spec) (Op, error) {
+			return Func(func(c Color) Color {
+				return Color{255 - c.R, 255 - c.G, 255 - c.B, c.A}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name:   "sepia",
+		Params: Params{"amount": 1},
+		New: func(s Spec) (Op, error) {
+			amount := float32(s.Params["amount"])
+			if amount < 0 || amount > 1 {
+				return nil, fmt.Errorf("sepia amount must be between 0 and 1, got %v", amount)
+			}
+			return Func(func(c Color) Color {
+				r := 0.393*c.R + 0.769*c.G + 0.189*c.B
+				g := 0.349*c.R + 0.686*c.G + 0.168*c.B
+				b := 0.272*c.R + 0.534*c.G + 0.131*c.B
+				return Color{
+					c.R + (r-c.R)*amount,
+					c.G + (g-c.G)*amount,
+					c.B + (b-c.B)*amount,
+					c.A,
+				}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name: "zero",
+		// A non-zero value zeroes the channel.
+		Params: Params{"r": 1, "g": 0, "b": 0, "a": 0},
+		New: func(s Spec) (Op, error) {
+			r, g, b, a := s.Params["r"] != 0, s.Params["g"] != 0, s.Params["b"] != 0, s.Params["a"] != 0
+			return Func(func(c Color) Color {
+				if r {
+					c.R = 0
+				}
+				if g {
+					c.G = 0
+				}
+				if b {
+					c.B = 0
+				}
+				if a {
+					c.A = 0
+				}
+				return c
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name: "brightness",
+		// Added to every colour channel.
+		Params: Params{"level": 0},
+		New: func(s Spec) (Op, error) {
+			level := float32(s.Params["level"])
+			if level < -255 || level > 255 {
+				return nil, fmt.Errorf("brightness level must be between -255 and 255, got %v", level)
+			}
+			return Func(func(c Color) Color {
+				return Color{c.R + level, c.G + level, c.B + level, c.A}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name: "contrast",
+		// Scales every colour channel away from, or towards, mid grey.
+		Params: Params{"factor": 1},
+		New: func(s Spec) (Op, error) {
+			factor := float32(s.Params["factor"])
+			if factor < 0 {
+				return nil, fmt.Errorf("contrast factor must not be negative, got %v", factor)
+			}
+			return Func(func(c Color) Color {
+				return Color{
+					(c.R-128)*factor + 128,
+					(c.G-128)*factor + 128,
+					(c.B-128)*factor + 128,
+					c.A,
+				}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name:   "gamma",
+		Params: Params{"gamma": 2.2},
+		New: func(s Spec) (Op, error) {
+			gamma := s.Params["gamma"]
+			if gamma <= 0 {
+				return nil, fmt.Errorf("gamma must be positive, got %v", gamma)
+			}
+			var table [256]float32
+			for i := range table {
+				table[i] = float32(255 * math.Pow(float64(i)/255, 1/gamma))
+			}
+			return Func(func(c Color) Color {
+				return Color{
+					table[toByte(c.R)],
+					table[toByte(c.G)],
+					table[toByte(c.B)],
+					c.A,
+				}
+			}), nil
+		},
+	})
+	Register(Definition{
+		Name: "threshold",
+		// Pixels whose luma reaches level turn white, the rest black.
+		Params: Params{"level": 128},
+		New: func(s Spec) (Op, error) {
+			level := float32(s.Params["level"])
+			if level < 0 || level > 255 {
+				return nil, fmt.Errorf("threshold level must be between 0 and 255, got %v", level)
+			}
+			return Func(func(c Color) Color {
+				if luma(c) >= level {
+					return Color{255, 255, 255, c.A}
+				}
+				return Color{0, 0, 0, c.A}
+			}), nil
+		},
+	})
+
+	Register(Definition{
+		Name:   "blur",
+		Params: Params{"radius": 2, "sigma": 0},
+		New: func(s Spec) (Op, error) {
+			radius, sigma, err := gaussianParams(s.Params)
+			if err != nil {
+				return nil, err
+			}
+			return &Convolution{
+				Kernels: []Kernel{GaussianKernel(radius, sigma)},
+				Alpha:   true,
+				Edge:    s.Edge,
+			}, nil
+		},
+	})
+	Register(Definition{
+		Name: "sharpen",
+		// An unsharp mask, adding amount times the difference between the
+		// image and its blurred copy.
+		Params: Params{"radius": 1, "sigma": 0, "amount": 1},
+		New: func(s Spec) (Op, error) {
+			radius, sigma, err := gaussianParams(s.Params)
+			if err != nil {
+				return nil, err
+			}
+			amount := float32(s.Params["amount"])
+			if amount < 0 {
+				return nil, fmt.Errorf("sharpen amount must not be negative, got %v", amount)
+			}
+			k := GaussianKernel(radius, sigma).Scale(-amount)
+			k.Weights[len(k.Weights)/2] += 1 + amount
+			return &Convolution{
+				Kernels: []Kernel{k},
+				Edge:    s.Edge,
+			}, nil
+		},
+	})
+	Register(Definition{
+		Name: "sobel",
+		New: func(s Spec) (Op, error) {
+			return &Convolution{
+				Kernels: []Kernel{
+					{Size: 3, Weights: []float32{-1, 0, 1, -2, 0, 2, -1, 0, 1}},
+					{Size: 3, Weights: []float32{-1, -2, -1, 0, 0, 0, 1, 2, 1}},
+				},
+				Combine: magnitude,
+				Edge:    s.Edge,
+			}, nil
+		},
+	})
+	Register(Definition{
+		Name: "laplacian",
+		New: func(s Spec) (Op, error) {
+			return &Convolution{
+				Kernels: []Kernel{{Size: 3, Weights: []float32{0, 1, 0, 1, -4, 1, 0, 1, 0}}},
+				Combine: absolute,
+				Edge:    s.Edge,
+			}, nil
+		},
+	})
+	Register(Definition{
+		Name: "kernel",
+		// The weights are divided by divisor, their sum when it is 0, and bias
+		// is added to the result. A non-zero alpha convolves the alpha channel
+		// as well.
+		Params: Params{"divisor": 0, "bias": 0, "alpha": 0},
+		Kernel: true,
+		New: func(s Spec) (Op, error) {
+			k, err := NewKernel(s.Kernel)
+			if err != nil {
+				return nil, err
+			}
+			divisor := float32(s.Params["divisor"])
+			if divisor == 0 {
+				for _, w := range k.Weights {
+					divisor += w
+				}
+				if divisor == 0 {
+					divisor = 1
+				}
+			}
+			return &Convolution{
+				Kernels: []Kernel{k.Scale(1 / divisor)},
+				Alpha:   s.Params["alpha"] != 0,
+				Bias:    float32(s.Params["bias"]),
+				Edge:    s.Edge,
+			}, nil
+		},
+	})
+	for name, resampling := range map[string]Resampling{
+		"resize":         Bilinear,
+		"resize-nearest": Nearest,
+		"resize-lanczos": Lanczos,
+	} {
+		resampling := resampling
+		Register(Definition{
+			Name: name,
+			// A width or height of 0 keeps the aspect ratio, scale sizes both
+			// when neither is set.
+			Params: Params{"width": 0, "height": 0, "scale": 0},
+			New: func(s Spec) (Op, error) {
+				width, height, scale := s.Params["width"], s.Params["height"], s.Params["scale"]
+				if width < 0 || height < 0 || scale < 0 || width != math.Trunc(width) || height != math.Trunc(height) {
+					return nil, fmt.Errorf("width and height must be whole numbers and scale positive")
+				}
+				if width == 0 && height == 0 && scale == 0 {
+					return nil, fmt.Errorf("one of width, height or scale must be set")
+				}
+				return &Resize{Width: int(width), Height: int(height), Scale: scale, Resampling: resampling}, nil
+			},
+		})
+	}
+	Register(Definition{
+		Name:   "crop",
+		Params: Params{"x": 0, "y": 0, "width": 0, "height": 0},
+		New: func(s Spec) (Op, error) {
+			c := &Crop{X: int(s.Params["x"]), Y: int(s.Params["y"]), Width: int(s.Params["width"]), Height: int(s.Params["height"])}
+			if c.Width < 1 || c.Height < 1 {
+				return nil, fmt.Errorf("crop width and height must be positive")
+			}
+			return c, nil
+		},
+	})
+	Register(Definition{
+		Name: "rotate",
+		// Angle is counterclockwise in degrees. A smooth of 0 picks the
+		// nearest pixel instead of blending, for angles that are not a
+		// multiple of 90.
+		Params: Params{"angle": 90, "smooth": 1},
+		New: func(s Spec) (Op, error) {
+			r := &Rotate{Angle: s.Params["angle"], Resampling: Bilinear}
+			if math.IsNaN(r.Angle) || math.IsInf(r.Angle, 0) {
+				return nil, fmt.Errorf("invalid angle %v", r.Angle)
+			}
+			if s.Params["smooth"] == 0 {
+				r.Resampling = Nearest
+			}
+			return r, nil
+		},
+	})
+	Register(Definition{
+		Name:   "flip",
+		Params: Params{"horizontal": 1, "vertical": 0},
+		New: func(s Spec) (Op, error) {
+			return &Flip{Horizontal: s.Params["horizontal"] != 0, Vertical: s.Params["vertical"] != 0}, nil
+		},
+	})
+}
+
+// gaussianParams reads the radius and sigma of a Gaussian kernel. A sigma
+// of 0 picks one matching the radius.
+func gaussianParams(p Params) (int, float64, error) {
+	radius, sigma := int(p["radius"]), p["sigma"]
+	if float64(radius) != p["radius"] || radius < 1 || radius > maxKernelSize/2 {
+		return 0, 0, fmt.Errorf("radius must be a whole number between 1 and %d, got %v", maxKernelSize/2, p["radius"])
+	}
+	if sigma < 0 {
+		return 0, 0, fmt.Errorf("sigma must not be negative, got %v", sigma)
+	}
+	if sigma == 0 {
+		sigma = math.Max(float64(radius)/2, 0.5)
+	}
+	return radius, sigma, nil
+}
+
+// luma returns the ITU-R BT.601 luma of c.
+func luma(c Color) float32 {
+	return 0.299*c.R + 0.587*c.G + 0.114*c.B
+}
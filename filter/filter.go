@@ -0,0 +1,224 @@
+// Package filter holds the pixel operations the Transform server can apply
+// to an image, looked up by name from a registry.
+package filter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// Color is a pixel with every channel in the 0-255 range, the same scale
+// used by pb.Color.
+type Color struct {
+	R, G, B, A float32
+}
+
+// Func transforms a single pixel.
+type Func func(c Color) Color
+
+// Op is a filter that may read neighbouring pixels to compute a pixel.
+type Op interface {
+	// Rows returns the range [lo, hi) of source rows needed to compute row y
+	// of the output for a source of width by height pixels.
+	Rows(y, width, height int) (lo, hi int)
+	// Row computes row y of the output from src into dst.
+	Row(src *Frame, y int, dst []Color)
+}
+
+// Sizer is implemented by ops whose output is not the size of their source.
+type Sizer interface {
+	// Size returns the size of the output for a source of width by height
+	// pixels, or an error when the op cannot apply to such a source.
+	Size(width, height int) (int, int, error)
+}
+
+// Rows implements Op, a pixel only needs itself.
+func (f Func) Rows(y, width, height int) (lo, hi int) {
+	return y, y + 1
+}
+
+// Row implements Op.
+func (f Func) Row(src *Frame, y int, dst []Color) {
+	row := src.Pix[y*src.Width : (y+1)*src.Width]
+	for x, c := range row {
+		dst[x] = f(c)
+	}
+}
+
+// Params are the numeric parameters a filter is created with.
+type Params map[string]float64
+
+// Spec selects a filter and everything it is created with.
+type Spec struct {
+	Name   string
+	Params Params
+	// Kernel holds the weights of a user supplied convolution kernel, row by
+	// row. Only filters with Definition.Kernel set accept one.
+	Kernel []float32
+	// Edge selects how filters reading neighbouring pixels treat the border
+	// of the image.
+	Edge EdgeMode
+}
+
+// Definition describes a filter that can be looked up by name.
+type Definition struct {
+	Name string
+	// Params lists every parameter the filter accepts with its default value.
+	Params Params
+	// Kernel reports whether the filter takes Spec.Kernel.
+	Kernel bool
+	// New creates the filter. s.Params always holds every key of Params.
+	New func(s Spec) (Op, error)
+}
+
+var (
+	mu          sync.RWMutex
+	definitions = make(map[string]Definition)
+)
+
+// Register makes a filter available under d.Name. It panics if the name is
+// already taken.
+func Register(d Definition) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := definitions[d.Name]; ok {
+		panic("filter: Register called twice for " + d.Name)
+	}
+	definitions[d.Name] = d
+}
+
+// Names returns the names of all registered filters in sorted order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	res := make([]string, 0, len(definitions))
+	for name := range definitions {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// New creates the filter registered as s.Name. Parameters missing from
+// s.Params take their default value, unknown ones are an error.
+func New(s Spec) (Op, error) {
+	mu.RLock()
+	d, ok := definitions[s.Name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q, expected one of %s", s.Name, strings.Join(Names(), ", "))
+	}
+	if len(s.Kernel) > 0 && !d.Kernel {
+		return nil, fmt.Errorf("filter %q does not take a kernel", s.Name)
+	}
+
+	merged := make(Params, len(d.Params))
+	for k, v := range d.Params {
+		merged[k] = v
+	}
+	for k, v := range s.Params {
+		if _, ok := d.Params[k]; !ok {
+			return nil, fmt.Errorf("filter %q has no parameter %q", s.Name, k)
+		}
+		merged[k] = v
+	}
+	s.Params = merged
+	return d.New(s)
+}
+
+// ParseParams parses parameters written as a comma separated list of
+// key=value pairs, e.g. "brightness=20,contrast=1.5".
+func ParseParams(s string) (Params, error) {
+	p := make(Params)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("parameter %q is not in the key=value form", kv)
+		}
+		key := strings.TrimSpace(kv[:i])
+		v, err := strconv.ParseFloat(strings.TrimSpace(kv[i+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %v", key, err)
+		}
+		p[key] = v
+	}
+	return p, nil
+}
+
+// ParseKernel parses kernel weights written as a comma separated list.
+func ParseKernel(s string) ([]float32, error) {
+	var res []float32
+	for _, w := range strings.Split(s, ",") {
+		if strings.TrimSpace(w) == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(w), 32)
+		if err != nil {
+			return nil, fmt.Errorf("kernel weight %q: %v", w, err)
+		}
+		res = append(res, float32(v))
+	}
+	return res, nil
+}
+
+// ColorFromProto converts c to a Color.
+func ColorFromProto(c *pb.Color) Color {
+	return Color{c.GetR(), c.GetG(), c.GetB(), c.GetA()}
+}
+
+// Proto converts c to a pb.Color, clamping every channel to the 0-255 range.
+func (c Color) Proto() *pb.Color {
+	return &pb.Color{R: clamp(c.R), G: clamp(c.G), B: clamp(c.B), A: clamp(c.A)}
+}
+
+// ApplyPixel runs f on c in place.
+func ApplyPixel(f Func, c *pb.Color) {
+	res := f(ColorFromProto(c))
+	c.R, c.G, c.B, c.A = clamp(res.R), clamp(res.G), clamp(res.B), clamp(res.A)
+}
+
+// ApplyRGBA runs f on every pixel of pix, packed 8-bit RGBA as carried by
+// pb.Tile, in place.
+func ApplyRGBA(f Func, pix []byte) {
+	for i := 0; i+4 <= len(pix); i += 4 {
+		res := f(Color{float32(pix[i]), float32(pix[i+1]), float32(pix[i+2]), float32(pix[i+3])})
+		pix[i] = toByte(res.R)
+		pix[i+1] = toByte(res.G)
+		pix[i+2] = toByte(res.B)
+		pix[i+3] = toByte(res.A)
+	}
+}
+
+// PackRGBA writes colors into dst as packed 8-bit RGBA, clamping every
+// channel to the 0-255 range. dst must hold 4 bytes per color.
+func PackRGBA(dst []byte, colors []Color) {
+	for i, c := range colors {
+		dst[i*4] = toByte(c.R)
+		dst[i*4+1] = toByte(c.G)
+		dst[i*4+2] = toByte(c.B)
+		dst[i*4+3] = toByte(c.A)
+	}
+}
+
+func clamp(v float32) float32 {
+	if v < 0 || v != v {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+func toByte(v float32) byte {
+	return byte(math.Round(float64(clamp(v))))
+}
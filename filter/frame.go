@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Frame is an image held as Colors, row by row.
+type Frame struct {
+	Width, Height int
+	Pix           []Color
+}
+
+// MaxPixels bounds the number of pixels of the images ops read and produce,
+// as codec.MaxPixels bounds the images decoded. A frame that large takes
+// 1 GiB.
+const MaxPixels = 1 << 26
+
+// maxDimension bounds the width and height of the images ops read and
+// produce.
+const maxDimension = 1 << 14
+
+// CheckSize returns an error unless an image of width by height pixels is
+// small enough to be held in frames: at most 16384 pixels along either side
+// and MaxPixels in all.
+func CheckSize(width, height int) error {
+	if width < 1 || height < 1 {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	if width > maxDimension || height > maxDimension || width*height > MaxPixels {
+		return fmt.Errorf("image of %dx%d pixels is larger than the %d pixels, %d along either side, allowed", width, height, MaxPixels, maxDimension)
+	}
+	return nil
+}
+
+// NewFrame returns a transparent black frame of the given size.
+func NewFrame(width, height int) *Frame {
+	return &Frame{
+		Width:  width,
+		Height: height,
+		Pix:    make([]Color, width*height),
+	}
+}
+
+// FrameFromImage copies img into a new frame. The top-left pixel of img
+// becomes (0, 0).
+func FrameFromImage(img image.Image) *Frame {
+	bounds := img.Bounds()
+	f := NewFrame(bounds.Dx(), bounds.Dy())
+	nrgba := image.NewNRGBA(image.Rect(0, 0, f.Width, f.Height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	for i := range f.Pix {
+		p := nrgba.Pix[i*4 : i*4+4]
+		f.Pix[i] = Color{float32(p[0]), float32(p[1]), float32(p[2]), float32(p[3])}
+	}
+	return f
+}
+
+// Image returns the frame as a non-premultiplied RGBA image.
+func (f *Frame) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for i, c := range f.Pix {
+		p := img.Pix[i*4 : i*4+4]
+		p[0], p[1], p[2], p[3] = toByte(c.R), toByte(c.G), toByte(c.B), toByte(c.A)
+	}
+	return img
+}
+
+// Row returns row y of the frame.
+func (f *Frame) Row(y int) []Color {
+	return f.Pix[y*f.Width : (y+1)*f.Width]
+}
+
+// At returns the pixel at (x, y), reading coordinates outside of the frame
+// as selected by edge.
+func (f *Frame) At(x, y int, edge EdgeMode) Color {
+	return f.Pix[edge.index(y, f.Height)*f.Width+edge.index(x, f.Width)]
+}
+
+// EdgeMode selects how pixels outside of an image are read.
+type EdgeMode int
+
+const (
+	// Clamp repeats the pixels on the border.
+	Clamp EdgeMode = iota
+	// Wrap reads from the opposite side of the image.
+	Wrap
+	// Mirror reflects the image at its border.
+	Mirror
+)
+
+var edgeModeNames = []string{"clamp", "wrap", "mirror"}
+
+func (e EdgeMode) String() string {
+	if e < 0 || int(e) >= len(edgeModeNames) {
+		return fmt.Sprintf("EdgeMode(%d)", int(e))
+	}
+	return edgeModeNames[e]
+}
+
+// ParseEdgeMode returns the edge mode named s. An empty string is Clamp.
+func ParseEdgeMode(s string) (EdgeMode, error) {
+	if s == "" {
+		return Clamp, nil
+	}
+	for i, name := range edgeModeNames {
+		if s == name {
+			return EdgeMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown edge mode %q, expected clamp, wrap or mirror", s)
+}
+
+// index maps i to a valid index of a dimension of n pixels.
+func (e EdgeMode) index(i, n int) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch e {
+	case Wrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case Mirror:
+		if i < 0 {
+			i = -i
+		} else {
+			i = 2*(n-1) - i
+		}
+	}
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// rows returns the range of rows [lo, hi) read by an op looking radius rows
+// around row y of an image height rows tall.
+func (e EdgeMode) rows(y, radius, height int) (lo, hi int) {
+	lo, hi = y-radius, y+radius+1
+	if lo >= 0 && hi <= height {
+		return lo, hi
+	}
+	if e == Wrap {
+		return 0, height
+	}
+	// Clamp and Mirror only read rows on the same side of the border.
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > height {
+		hi = height
+	}
+	return lo, hi
+}
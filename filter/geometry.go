@@ -0,0 +1,321 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Resampling selects how a resized or rotated image reads between source
+// pixels.
+type Resampling struct {
+	// Support is the distance from the sampled point, in source pixels at a
+	// scale of 1, over which source pixels contribute.
+	Support float64
+	// Weight returns the contribution of a source pixel at distance t.
+	Weight func(t float64) float64
+}
+
+var (
+	// Nearest picks the closest source pixel.
+	Nearest = Resampling{}
+	// Bilinear blends the closest source pixels linearly.
+	Bilinear = Resampling{Support: 1, Weight: func(t float64) float64 {
+		if t < 0 {
+			t = -t
+		}
+		if t < 1 {
+			return 1 - t
+		}
+		return 0
+	}}
+	// Lanczos uses a three lobed Lanczos window, keeping edges sharp.
+	Lanczos = Resampling{Support: 3, Weight: func(t float64) float64 {
+		if t < 0 {
+			t = -t
+		}
+		if t < 1e-9 {
+			return 1
+		}
+		if t >= 3 {
+			return 0
+		}
+		x := math.Pi * t
+		return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+	}}
+)
+
+// taps lists the source pixels contributing to an output pixel along one
+// axis, with their normalised weights.
+type taps struct {
+	first   int
+	weights []float32
+}
+
+// axis computes the taps of output pixel i of n when resampling a source of
+// size pixels.
+func (r Resampling) axis(i, n, size int) taps {
+	scale := float64(size) / float64(n)
+	center := (float64(i)+0.5)*scale - 0.5
+	if r.Weight == nil {
+		p := int(math.Floor(center + 0.5))
+		return taps{first: clampInt(p, 0, size-1), weights: []float32{1}}
+	}
+
+	filterScale := math.Max(scale, 1)
+	radius := r.Support * filterScale
+	lo := int(math.Ceil(center - radius))
+	hi := int(math.Floor(center + radius))
+	res := taps{first: lo, weights: make([]float32, 0, hi-lo+1)}
+	var sum float64
+	for p := lo; p <= hi; p++ {
+		sum += r.Weight((float64(p) - center) / filterScale)
+	}
+	for p := lo; p <= hi; p++ {
+		res.weights = append(res.weights, float32(r.Weight((float64(p)-center)/filterScale)/sum))
+	}
+	return res
+}
+
+// span returns the source rows read by t, clamped to a source of size rows.
+func (t taps) span(size int) (lo, hi int) {
+	return clampInt(t.first, 0, size-1), clampInt(t.first+len(t.weights)-1, 0, size-1) + 1
+}
+
+// Resize scales an image to a new size.
+type Resize struct {
+	Width, Height int
+	// Scale scales both dimensions when both are left at 0. When only one
+	// is, it keeps the aspect ratio of the source and Scale is ignored.
+	Scale      float64
+	Resampling Resampling
+
+	mu      sync.Mutex
+	columns []taps // of every output column, computed by the first Row
+}
+
+// Size implements Sizer.
+func (r *Resize) Size(width, height int) (int, int, error) {
+	w, h := r.Width, r.Height
+	switch {
+	case w == 0 && h == 0 && r.Scale > 0:
+		w, h = int(math.Round(float64(width)*r.Scale)), int(math.Round(float64(height)*r.Scale))
+	case w == 0 && h > 0:
+		w = int(math.Round(float64(width) * float64(h) / float64(height)))
+	case h == 0 && w > 0:
+		h = int(math.Round(float64(height) * float64(w) / float64(width)))
+	}
+	if w < 1 || h < 1 || w > maxDimension || h > maxDimension {
+		return 0, 0, fmt.Errorf("cannot resize a %dx%d image to %dx%d", width, height, w, h)
+	}
+	return w, h, nil
+}
+
+// Rows implements Op.
+func (r *Resize) Rows(y, width, height int) (lo, hi int) {
+	_, h, _ := r.Size(width, height)
+	return r.Resampling.axis(y, h, height).span(height)
+}
+
+// Row implements Op.
+func (r *Resize) Row(src *Frame, y int, dst []Color) {
+	_, h, _ := r.Size(src.Width, src.Height)
+	ty := r.Resampling.axis(y, h, src.Height)
+	r.mu.Lock()
+	if len(r.columns) != len(dst) {
+		r.columns = make([]taps, len(dst))
+		for x := range dst {
+			r.columns[x] = r.Resampling.axis(x, len(dst), src.Width)
+		}
+	}
+	columns := r.columns
+	r.mu.Unlock()
+
+	for x, tx := range columns {
+		var c Color
+		for j, wy := range ty.weights {
+			for i, wx := range tx.weights {
+				p := src.At(tx.first+i, ty.first+j, Clamp)
+				w := wx * wy
+				c.R += w * p.R
+				c.G += w * p.G
+				c.B += w * p.B
+				c.A += w * p.A
+			}
+		}
+		dst[x] = c
+	}
+}
+
+// Crop keeps a rectangle of an image.
+type Crop struct {
+	X, Y, Width, Height int
+}
+
+// Size implements Sizer.
+func (c *Crop) Size(width, height int) (int, int, error) {
+	if c.X < 0 || c.Y < 0 || c.Width < 1 || c.Height < 1 || c.X+c.Width > width || c.Y+c.Height > height {
+		return 0, 0, fmt.Errorf("crop of %dx%d at (%d, %d) does not fit in the %dx%d image", c.Width, c.Height, c.X, c.Y, width, height)
+	}
+	return c.Width, c.Height, nil
+}
+
+// Rows implements Op.
+func (c *Crop) Rows(y, width, height int) (lo, hi int) {
+	return c.Y + y, c.Y + y + 1
+}
+
+// Row implements Op.
+func (c *Crop) Row(src *Frame, y int, dst []Color) {
+	copy(dst, src.Row(c.Y + y)[c.X:])
+}
+
+// Flip mirrors an image.
+type Flip struct {
+	Horizontal, Vertical bool
+}
+
+// Rows implements Op.
+func (f *Flip) Rows(y, width, height int) (lo, hi int) {
+	if f.Vertical {
+		y = height - 1 - y
+	}
+	return y, y + 1
+}
+
+// Row implements Op.
+func (f *Flip) Row(src *Frame, y int, dst []Color) {
+	lo, _ := f.Rows(y, src.Width, src.Height)
+	row := src.Row(lo)
+	for x := range dst {
+		if f.Horizontal {
+			dst[x] = row[len(row)-1-x]
+		} else {
+			dst[x] = row[x]
+		}
+	}
+}
+
+// Rotate turns an image counterclockwise by an angle in degrees. The output
+// is large enough to hold the whole rotated image, corners outside of the
+// source are transparent. Multiples of 90 degrees move pixels without
+// resampling.
+type Rotate struct {
+	Angle      float64
+	Resampling Resampling
+}
+
+// quarter returns the number of counterclockwise quarter turns when the
+// angle is a multiple of 90 degrees.
+func (r *Rotate) quarter() (int, bool) {
+	a := math.Mod(r.Angle, 360)
+	if a < 0 {
+		a += 360
+	}
+	if math.Mod(a, 90) != 0 {
+		return 0, false
+	}
+	return int(a / 90), true
+}
+
+// Size implements Sizer.
+func (r *Rotate) Size(width, height int) (int, int, error) {
+	if q, ok := r.quarter(); ok {
+		if q%2 == 1 {
+			return height, width, nil
+		}
+		return width, height, nil
+	}
+	sin, cos := math.Sincos(r.Angle * math.Pi / 180)
+	w := int(math.Ceil(math.Abs(float64(width)*cos) + math.Abs(float64(height)*sin) - 1e-9))
+	h := int(math.Ceil(math.Abs(float64(width)*sin) + math.Abs(float64(height)*cos) - 1e-9))
+	if w > maxDimension || h > maxDimension {
+		return 0, 0, fmt.Errorf("rotating a %dx%d image makes it too large", width, height)
+	}
+	return w, h, nil
+}
+
+// source maps the centre of output pixel (x, y) to source coordinates.
+func (r *Rotate) source(x, y float64, width, height int) (float64, float64) {
+	w, h, _ := r.Size(width, height)
+	sin, cos := math.Sincos(r.Angle * math.Pi / 180)
+	dx, dy := x+0.5-float64(w)/2, y+0.5-float64(h)/2
+	// The y axis points down, so a counterclockwise turn on screen is a
+	// clockwise one in image coordinates.
+	return dx*cos - dy*sin + float64(width)/2 - 0.5, dx*sin + dy*cos + float64(height)/2 - 0.5
+}
+
+// Rows implements Op.
+func (r *Rotate) Rows(y, width, height int) (lo, hi int) {
+	if q, ok := r.quarter(); ok {
+		switch q {
+		case 0:
+			return y, y + 1
+		case 2:
+			return height - 1 - y, height - y
+		}
+		return 0, height
+	}
+	w, _, _ := r.Size(width, height)
+	_, y0 := r.source(0, float64(y), width, height)
+	_, y1 := r.source(float64(w-1), float64(y), width, height)
+	lo = clampInt(int(math.Floor(math.Min(y0, y1)))-1, 0, height)
+	hi = clampInt(int(math.Ceil(math.Max(y0, y1)))+2, 0, height)
+	return lo, hi
+}
+
+// Row implements Op.
+func (r *Rotate) Row(src *Frame, y int, dst []Color) {
+	if q, ok := r.quarter(); ok {
+		for x := range dst {
+			switch q {
+			case 0:
+				dst[x] = src.Pix[y*src.Width+x]
+			case 1:
+				dst[x] = src.Pix[x*src.Width+src.Width-1-y]
+			case 2:
+				dst[x] = src.Pix[(src.Height-1-y)*src.Width+src.Width-1-x]
+			case 3:
+				dst[x] = src.Pix[(src.Height-1-x)*src.Width+y]
+			}
+		}
+		return
+	}
+
+	for x := range dst {
+		sx, sy := r.source(float64(x), float64(y), src.Width, src.Height)
+		if sx < -0.5 || sy < -0.5 || sx > float64(src.Width)-0.5 || sy > float64(src.Height)-0.5 {
+			dst[x] = Color{}
+			continue
+		}
+		if r.Resampling.Weight == nil {
+			dst[x] = src.At(int(math.Floor(sx+0.5)), int(math.Floor(sy+0.5)), Clamp)
+			continue
+		}
+		// Bilinear sampling of the four pixels around (sx, sy).
+		x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+		fx, fy := float32(sx-float64(x0)), float32(sy-float64(y0))
+		var c Color
+		for j := 0; j < 2; j++ {
+			for i := 0; i < 2; i++ {
+				w := (1 - fx + float32(i)*(2*fx-1)) * (1 - fy + float32(j)*(2*fy-1))
+				p := src.At(x0+i, y0+j, Clamp)
+				c.R += w * p.R
+				c.G += w * p.G
+				c.B += w * p.B
+				c.A += w * p.A
+			}
+		}
+		dst[x] = c
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxKernelSize bounds the work a single pixel can ask for.
+const maxKernelSize = 21
+
+// Kernel is a square convolution matrix with an odd number of rows.
+type Kernel struct {
+	Size    int
+	Weights []float32
+}
+
+// NewKernel checks that weights form a square matrix with an odd number of
+// rows and returns it as a kernel.
+func NewKernel(weights []float32) (Kernel, error) {
+	size := int(math.Sqrt(float64(len(weights))))
+	if size*size != len(weights) || size%2 == 0 {
+		return Kernel{}, fmt.Errorf("kernel needs an odd square number of weights, got %d", len(weights))
+	}
+	if size > maxKernelSize {
+		return Kernel{}, fmt.Errorf("kernel may be at most %dx%d, got %dx%d", maxKernelSize, maxKernelSize, size, size)
+	}
+	return Kernel{Size: size, Weights: weights}, nil
+}
+
+// Scale returns the kernel with every weight multiplied by s.
+func (k Kernel) Scale(s float32) Kernel {
+	res := Kernel{Size: k.Size, Weights: make([]float32, len(k.Weights))}
+	for i, w := range k.Weights {
+		res.Weights[i] = w * s
+	}
+	return res
+}
+
+// GaussianKernel returns a normalised Gaussian kernel reaching radius pixels
+// from its centre.
+func GaussianKernel(radius int, sigma float64) Kernel {
+	size := 2*radius + 1
+	k := Kernel{Size: size, Weights: make([]float32, size*size)}
+	var sum float64
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			sum += math.Exp(-float64(x*x+y*y) / (2 * sigma * sigma))
+		}
+	}
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			w := math.Exp(-float64(x*x+y*y) / (2 * sigma * sigma))
+			k.Weights[(y+radius)*size+x+radius] = float32(w / sum)
+		}
+	}
+	return k
+}
+
+// Convolution is an Op computing every pixel from the pixels around it.
+type Convolution struct {
+	Kernels []Kernel
+	// Combine merges the result of every kernel for a channel. When nil the
+	// result of the first kernel is used.
+	Combine func(v []float32) float32
+	// Alpha convolves the alpha channel as well instead of keeping the alpha
+	// of the source pixel.
+	Alpha bool
+	Bias  float32
+	Edge  EdgeMode
+}
+
+func (c *Convolution) radius() int {
+	r := 0
+	for _, k := range c.Kernels {
+		if k.Size/2 > r {
+			r = k.Size / 2
+		}
+	}
+	return r
+}
+
+// Rows implements Op.
+func (c *Convolution) Rows(y, width, height int) (lo, hi int) {
+	return c.Edge.rows(y, c.radius(), height)
+}
+
+// Row implements Op.
+func (c *Convolution) Row(src *Frame, y int, dst []Color) {
+	var (
+		r    = make([]float32, len(c.Kernels))
+		g    = make([]float32, len(c.Kernels))
+		b    = make([]float32, len(c.Kernels))
+		a    = make([]float32, len(c.Kernels))
+		pick = c.Combine
+	)
+	if pick == nil {
+		pick = func(v []float32) float32 { return v[0] }
+	}
+
+	for x := range dst {
+		for i, k := range c.Kernels {
+			half := k.Size / 2
+			var sr, sg, sb, sa float32
+			for ky := 0; ky < k.Size; ky++ {
+				for kx := 0; kx < k.Size; kx++ {
+					w := k.Weights[ky*k.Size+kx]
+					if w == 0 {
+						continue
+					}
+					p := src.At(x+kx-half, y+ky-half, c.Edge)
+					sr += w * p.R
+					sg += w * p.G
+					sb += w * p.B
+					sa += w * p.A
+				}
+			}
+			r[i], g[i], b[i], a[i] = sr, sg, sb, sa
+		}
+
+		res := Color{pick(r) + c.Bias, pick(g) + c.Bias, pick(b) + c.Bias, src.Pix[y*src.Width+x].A}
+		if c.Alpha {
+			res.A = pick(a)
+		}
+		dst[x] = res
+	}
+}
+
+// magnitude combines the horizontal and vertical gradient of a Sobel filter.
+func magnitude(v []float32) float32 {
+	return float32(math.Sqrt(float64(v[0]*v[0] + v[1]*v[1])))
+}
+
+// absolute keeps the strength of an edge regardless of its direction.
+func absolute(v []float32) float32 {
+	if v[0] < 0 {
+		return -v[0]
+	}
+	return v[0]
+}
@@ -0,0 +1,155 @@
+package filter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys a client sets on a Transform stream to select the filter.
+const (
+	// MetadataName holds the filter name, DefaultName when missing.
+	MetadataName = "filter"
+	// MetadataParams holds the filter parameters in the form read by
+	// ParseParams.
+	MetadataParams = "filter-params"
+	// MetadataKernel holds the weights read by ParseKernel.
+	MetadataKernel = "filter-kernel"
+	// MetadataEdge holds the name of the edge mode, clamp when missing.
+	MetadataEdge = "filter-edge"
+)
+
+// Metadata keys announcing the size of the image sent on a Transform stream.
+// Filters reading neighbouring pixels need them.
+const (
+	MetadataWidth  = "image-width"
+	MetadataHeight = "image-height"
+)
+
+// Header keys announcing the size of the image a Transform stream sends back,
+// which differs from the source when the pipeline resizes, crops or rotates.
+const (
+	MetadataOutputWidth  = "output-width"
+	MetadataOutputHeight = "output-height"
+)
+
+// MetadataOrdered is the metadata key a client sets to "true" on a Transform
+// stream to receive the results in the order it sent the pixels. They are
+// sent back as soon as they are ready otherwise.
+const MetadataOrdered = "ordered-output"
+
+// MetadataProgressInterval is the metadata key a client sets on a Transform
+// stream to a duration, such as "500ms", to receive Progress messages at
+// most that often. Shorter durations than MinProgressInterval are raised to
+// it.
+const MetadataProgressInterval = "progress-interval"
+
+// MinProgressInterval bounds how often progress is reported.
+const MinProgressInterval = 100 * time.Millisecond
+
+// FromIncomingContext creates the filter selected by the metadata of an
+// incoming call. Unknown filters and bad parameters are reported with
+// codes.InvalidArgument.
+func FromIncomingContext(ctx context.Context) (Op, error) {
+	step, err := StepFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := SpecFromProto(step)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	op, err := New(s)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return op, nil
+}
+
+// StepFromIncomingContext returns the filter selected by the metadata of an
+// incoming call as a step of a pipeline, without checking that it exists.
+// Parameters and kernels that cannot be parsed are reported with
+// codes.InvalidArgument.
+func StepFromIncomingContext(ctx context.Context) (*pb.Step, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	step := &pb.Step{Filter: first(md, MetadataName), Edge: first(md, MetadataEdge)}
+	if step.Filter == "" {
+		step.Filter = DefaultName
+	}
+
+	var err error
+	if step.Params, err = ParseParams(first(md, MetadataParams)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if step.Kernel, err = ParseKernel(first(md, MetadataKernel)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return step, nil
+}
+
+// ImageSizeFromIncomingContext returns the image size announced in the
+// metadata of an incoming call, or 0, 0 when it is missing. Sizes rejected
+// by CheckSize are reported with codes.InvalidArgument.
+func ImageSizeFromIncomingContext(ctx context.Context) (width, height int, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	w, h := first(md, MetadataWidth), first(md, MetadataHeight)
+	if w == "" && h == "" {
+		return 0, 0, nil
+	}
+	if width, err = strconv.Atoi(w); err != nil || width <= 0 {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "invalid %s %q", MetadataWidth, w)
+	}
+	if height, err = strconv.Atoi(h); err != nil || height <= 0 {
+		return 0, 0, status.Errorf(codes.InvalidArgument, "invalid %s %q", MetadataHeight, h)
+	}
+	if err := CheckSize(width, height); err != nil {
+		return 0, 0, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return width, height, nil
+}
+
+// OrderedFromIncomingContext reports whether an incoming call asked for
+// ordered results with MetadataOrdered.
+func OrderedFromIncomingContext(ctx context.Context) (bool, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := first(md, MetadataOrdered)
+	if v == "" {
+		return false, nil
+	}
+	ordered, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "invalid %s %q", MetadataOrdered, v)
+	}
+	return ordered, nil
+}
+
+// ProgressIntervalFromIncomingContext returns how often an incoming call
+// asked for its progress with MetadataProgressInterval, 0 when it did not.
+func ProgressIntervalFromIncomingContext(ctx context.Context) (time.Duration, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := first(md, MetadataProgressInterval)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid %s %q", MetadataProgressInterval, v)
+	}
+	if d < MinProgressInterval {
+		d = MinProgressInterval
+	}
+	return d, nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,305 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxSteps bounds the length of a pipeline.
+const MaxSteps = 16
+
+// StepError reports the step of a pipeline that could not be created.
+type StepError struct {
+	Step int
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// NewPipeline creates the filter of every spec, in order. A step that
+// cannot be created is reported as a *StepError.
+func NewPipeline(specs []Spec) ([]Op, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("pipeline has no steps")
+	}
+	if len(specs) > MaxSteps {
+		return nil, fmt.Errorf("pipeline has %d steps, at most %d are allowed", len(specs), MaxSteps)
+	}
+	ops := make([]Op, len(specs))
+	for i, s := range specs {
+		op, err := New(s)
+		if err != nil {
+			return nil, &StepError{Step: i, Err: err}
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// Fuse merges consecutive Funcs of ops into one, so they run in a single
+// pass over the image.
+func Fuse(ops []Op) []Op {
+	var res []Op
+	for _, op := range ops {
+		f, ok := op.(Func)
+		if !ok {
+			res = append(res, op)
+			continue
+		}
+		if prev, ok := lastFunc(res); ok {
+			res[len(res)-1] = Func(func(c Color) Color { return f(prev(c)) })
+			continue
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+func lastFunc(ops []Op) (Func, bool) {
+	if len(ops) == 0 {
+		return nil, false
+	}
+	f, ok := ops[len(ops)-1].(Func)
+	return f, ok
+}
+
+// SpecFromProto converts a step of a pb.Pipeline to a Spec.
+func SpecFromProto(step *pb.Step) (Spec, error) {
+	edge, err := ParseEdgeMode(step.GetEdge())
+	if err != nil {
+		return Spec{}, err
+	}
+	return Spec{
+		Name:   step.GetFilter(),
+		Params: step.GetParams(),
+		Kernel: step.GetKernel(),
+		Edge:   edge,
+	}, nil
+}
+
+// Select returns the ops a Transform stream asked for: the steps of p when
+// it is set, otherwise the filter selected by the metadata of ctx. Errors are
+// reported as by Status.
+func Select(ctx context.Context, p *pb.Pipeline) ([]Op, error) {
+	if p == nil {
+		op, err := FromIncomingContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []Op{op}, nil
+	}
+
+	specs := make([]Spec, len(p.Steps))
+	for i, step := range p.Steps {
+		s, err := SpecFromProto(step)
+		if err != nil {
+			return nil, Status(&StepError{Step: i, Err: err})
+		}
+		specs[i] = s
+	}
+	ops, err := NewPipeline(specs)
+	if err != nil {
+		return nil, Status(err)
+	}
+	return ops, nil
+}
+
+// Status converts an error of this package to a gRPC status error with
+// codes.InvalidArgument. A *StepError carries a errdetails.BadRequest naming
+// the failing step of the pipeline.
+func Status(err error) error {
+	e, ok := err.(*StepError)
+	if !ok {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	st := status.New(codes.InvalidArgument, "invalid pipeline: "+e.Error())
+	br := &errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       fmt.Sprintf("pipeline.steps[%d]", e.Step),
+			Description: e.Err.Error(),
+		}},
+	}
+	if withDetails, err := st.WithDetails(br); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// OutputSize returns the size of the image ops produce from a source of
+// width by height pixels. A source rejected by CheckSize is reported as is,
+// a step that cannot apply to the image it receives or produces an image
+// CheckSize rejects as a *StepError.
+func OutputSize(width, height int, ops ...Op) (int, int, error) {
+	if err := CheckSize(width, height); err != nil {
+		return 0, 0, err
+	}
+	for i, op := range ops {
+		if s, ok := op.(Sizer); ok {
+			var err error
+			if width, height, err = s.Size(width, height); err != nil {
+				return 0, 0, &StepError{Step: i, Err: err}
+			}
+			if err = CheckSize(width, height); err != nil {
+				return 0, 0, &StepError{Step: i, Err: err}
+			}
+		}
+	}
+	return width, height, nil
+}
+
+// ParsePipeline parses a pipeline written as steps separated by "|", each a
+// filter name optionally followed by ":" and its parameters in the form read
+// by ParseParams, e.g. "grayscale|sharpen:amount=2". Two parameters are
+// special: edge names the edge mode and kernel holds space separated
+// weights, e.g. "kernel:kernel=0 -1 0 -1 5 -1 0 -1 0,edge=mirror".
+func ParsePipeline(s string) (*pb.Pipeline, error) {
+	p := &pb.Pipeline{}
+	for i, step := range strings.Split(s, "|") {
+		name, params := strings.TrimSpace(step), ""
+		if j := strings.Index(step, ":"); j >= 0 {
+			name, params = strings.TrimSpace(step[:j]), step[j+1:]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("step %d has no filter name", i)
+		}
+
+		ps := &pb.Step{Filter: name}
+		var rest []string
+		for _, kv := range strings.Split(params, ",") {
+			switch k := strings.TrimSpace(strings.SplitN(kv, "=", 2)[0]); k {
+			case "edge", "kernel":
+				v := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(kv), k+"="))
+				if k == "edge" {
+					ps.Edge = v
+					continue
+				}
+				kernel, err := ParseKernel(strings.Join(strings.Fields(v), ","))
+				if err != nil {
+					return nil, fmt.Errorf("step %d: %v", i, err)
+				}
+				ps.Kernel = kernel
+			default:
+				rest = append(rest, kv)
+			}
+		}
+		values, err := ParseParams(strings.Join(rest, ","))
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %v", i, err)
+		}
+		if len(values) > 0 {
+			ps.Params = values
+		}
+		p.Steps = append(p.Steps, ps)
+	}
+	return p, nil
+}
+
+// Apply runs ops one after the other over the whole of src and returns the
+// result. Errors are reported as by OutputSize.
+func Apply(src *Frame, ops ...Op) (*Frame, error) {
+	return apply(context.Background(), src, nil, nil, ops)
+}
+
+// progressRows is the number of rows computed by ApplyContext between two
+// checks of its context.
+const progressRows = 64
+
+// PassHook is called as a pass of ApplyContext over an image starts, with
+// the index of the pass, its op and the size of the image it computes.
+// Consecutive steps working pixel by pixel run as one pass, of a Func. The
+// function returned, which may be nil, is called as the pass ends, with the
+// error stopping it, nil when it completed.
+type PassHook func(pass int, op Op, width, height int) func(error)
+
+// ApplyContext runs ops over the part of src in r, as ApplyRegion does. It
+// stops with the error of ctx once ctx is done, and reports the fraction of
+// the rows computed so far to progress and every pass to pass, both of which
+// may be nil.
+func ApplyContext(ctx context.Context, src *Frame, r *Region, progress func(float64), pass PassHook, ops ...Op) (*Frame, error) {
+	if r == nil {
+		return apply(ctx, src, progress, pass, ops)
+	}
+	if r.X+r.Width > src.Width || r.Y+r.Height > src.Height {
+		return nil, fmt.Errorf("region of %dx%d at (%d, %d) does not fit in the %dx%d image", r.Width, r.Height, r.X, r.Y, src.Width, src.Height)
+	}
+	if err := r.checkSize(ops); err != nil {
+		return nil, err
+	}
+
+	part := NewFrame(r.Width, r.Height)
+	for y := 0; y < r.Height; y++ {
+		copy(part.Row(y), src.Row(r.Y + y)[r.X:])
+	}
+	part, err := apply(ctx, part, progress, pass, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewFrame(src.Width, src.Height)
+	copy(res.Pix, src.Pix)
+	for y := 0; y < r.Height; y++ {
+		row, filtered := res.Row(r.Y + y)[r.X:], part.Row(y)
+		for x := 0; x < r.Width; x++ {
+			row[x] = r.Blend(x, y, row[x], filtered[x])
+		}
+	}
+	return res, nil
+}
+
+func apply(ctx context.Context, src *Frame, progress func(float64), pass PassHook, ops []Op) (*Frame, error) {
+	if _, _, err := OutputSize(src.Width, src.Height, ops...); err != nil {
+		return nil, err
+	}
+	ops = Fuse(ops)
+	var total, done int
+	width, height := src.Width, src.Height
+	for _, op := range ops {
+		width, height, _ = OutputSize(width, height, op)
+		total += height
+	}
+
+	for i, op := range ops {
+		width, height, _ := OutputSize(src.Width, src.Height, op)
+		var end func(error)
+		if pass != nil {
+			end = pass(i, op, width, height)
+		}
+		dst := NewFrame(width, height)
+		for y := 0; y < height; y++ {
+			if done%progressRows == 0 {
+				if err := ctx.Err(); err != nil {
+					if end != nil {
+						end(err)
+					}
+					return nil, err
+				}
+				if progress != nil {
+					progress(float64(done) / float64(total))
+				}
+			}
+			op.Row(src, y, dst.Row(y))
+			done++
+		}
+		if end != nil {
+			end(nil)
+		}
+		src = dst
+	}
+	if progress != nil {
+		progress(1)
+	}
+	return src, nil
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+
+	pb "nichowil/grpc-tutorial/transform"
+)
+
+// Region is the part of an image a pipeline is limited to.
+type Region struct {
+	X, Y, Width, Height int
+	// Mask holds the coverage of every pixel of the region, row by row. It
+	// is nil when the region is fully covered.
+	Mask []byte
+}
+
+// RegionFromProto converts r to a Region, nil when r is nil. A width or
+// height of 0 is the size of the image, which must then be known.
+func RegionFromProto(r *pb.Region, width, height int) (*Region, error) {
+	if r == nil {
+		return nil, nil
+	}
+	res := &Region{
+		X:      int(r.GetOrigin().GetX()),
+		Y:      int(r.GetOrigin().GetY()),
+		Width:  int(r.GetWidth()),
+		Height: int(r.GetHeight()),
+	}
+	if res.Width == 0 && res.Height == 0 && width > 0 {
+		res.Width, res.Height = width-res.X, height-res.Y
+	}
+	if res.X < 0 || res.Y < 0 || res.Width < 1 || res.Height < 1 || res.Width > maxDimension || res.Height > maxDimension {
+		return nil, fmt.Errorf("invalid region of %dx%d at (%d, %d)", res.Width, res.Height, res.X, res.Y)
+	}
+	if width > 0 && (res.X+res.Width > width || res.Y+res.Height > height) {
+		return nil, fmt.Errorf("region of %dx%d at (%d, %d) does not fit in the %dx%d image", res.Width, res.Height, res.X, res.Y, width, height)
+	}
+	if mask := r.GetMask(); len(mask) > 0 {
+		if len(mask) != res.Width*res.Height {
+			return nil, fmt.Errorf("mask of %d bytes does not cover the %dx%d region", len(mask), res.Width, res.Height)
+		}
+		res.Mask = mask
+	}
+	return res, nil
+}
+
+// Contains reports whether the pixel at (x, y) of the image is in r. A nil
+// region contains every pixel.
+func (r *Region) Contains(x, y int) bool {
+	return r == nil || x >= r.X && y >= r.Y && x < r.X+r.Width && y < r.Y+r.Height
+}
+
+// Blend returns the pixel at (x, y) of the region, given its source color
+// and its filtered color res, weighted by the mask.
+func (r *Region) Blend(x, y int, src, res Color) Color {
+	if r == nil || r.Mask == nil {
+		return res
+	}
+	m := r.Mask[y*r.Width+x]
+	switch m {
+	case 0:
+		return src
+	case 255:
+		return res
+	}
+	t := float32(m) / 255
+	return Color{
+		R: src.R + t*(res.R-src.R),
+		G: src.G + t*(res.G-src.G),
+		B: src.B + t*(res.B-src.B),
+		A: src.A + t*(res.A-src.A),
+	}
+}
+
+// checkSize reports ops that would change the size of the region, whose
+// result could not be put back in the image.
+func (r *Region) checkSize(ops []Op) error {
+	width, height, err := OutputSize(r.Width, r.Height, ops...)
+	if err != nil {
+		return err
+	}
+	if width != r.Width || height != r.Height {
+		return fmt.Errorf("steps limited to a region must keep its size of %dx%d, not make it %dx%d", r.Width, r.Height, width, height)
+	}
+	return nil
+}
+
+// NewRegionWindow returns a window running ops over r, whose rows are the
+// rows of the region. Errors are reported as by OutputSize.
+func NewRegionWindow(r *Region, ops ...Op) (*Window, error) {
+	if err := r.checkSize(ops); err != nil {
+		return nil, err
+	}
+	return NewWindow(r.Width, r.Height, ops...)
+}
+
+// ApplyRegion runs ops over the part of src in r, as Apply does, and blends
+// the result back into a copy of src. A nil region applies ops to the whole
+// of src.
+func ApplyRegion(src *Frame, r *Region, ops ...Op) (*Frame, error) {
+	return ApplyContext(context.Background(), src, r, nil, nil, ops...)
+}
@@ -0,0 +1,232 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Window runs a chain of ops over an image whose pixels arrive in any order.
+// Each op computes a row as soon as every source row it reads from has been
+// filled, so the output of the last op is reported row by row while the
+// image is still arriving.
+//
+// Since pixels may arrive in any order, and ops such as Rotate read every
+// row, a window holds the whole source of every op, ops working pixel by
+// pixel being fused into one: 16 bytes per pixel of each of them. Sizes are
+// bounded by CheckSize.
+type Window struct {
+	stages []*stage
+}
+
+// stage is one op of a window with the rows of its source image.
+type stage struct {
+	op     Op
+	src    *Frame
+	seen   []bool // of the first stage
+	filled []int  // counts the pixels of each row, of the first stage
+	width  int    // of the output
+	done   []bool
+	full   rowSet
+	// spans groups the output rows reading the same source rows, and
+	// waiting[y] lists the spans whose last source row not full is y, so
+	// that a row only checks the spans it may complete.
+	spans   []span
+	waiting [][]int
+}
+
+// span is a range [first, end) of output rows reading the source rows
+// [lo, hi).
+type span struct {
+	first, end int
+	lo, hi     int
+}
+
+// newStage returns the stage running op over a source of width by height
+// pixels into an output outHeight rows tall.
+func newStage(op Op, width, height, outWidth, outHeight int) *stage {
+	s := &stage{
+		op:      op,
+		src:     NewFrame(width, height),
+		width:   outWidth,
+		done:    make([]bool, outHeight),
+		full:    newRowSet(height),
+		waiting: make([][]int, height),
+	}
+	for y := 0; y < outHeight; y++ {
+		lo, hi := op.Rows(y, width, height)
+		if n := len(s.spans); n > 0 && s.spans[n-1].lo == lo && s.spans[n-1].hi == hi {
+			s.spans[n-1].end++
+			continue
+		}
+		s.spans = append(s.spans, span{first: y, end: y + 1, lo: lo, hi: hi})
+	}
+	for i, sp := range s.spans {
+		// rows reading no source row are ready once the first one is
+		y := sp.hi - 1
+		if y < sp.lo || y < 0 || y >= height {
+			y = 0
+		}
+		s.waiting[y] = append(s.waiting[y], i)
+	}
+	return s
+}
+
+// NewWindow returns a window running ops, one after the other, over an image
+// of the given size. Errors are reported as by OutputSize.
+func NewWindow(width, height int, ops ...Op) (*Window, error) {
+	if _, _, err := OutputSize(width, height, ops...); err != nil {
+		return nil, err
+	}
+	w := &Window{}
+	for _, op := range Fuse(ops) {
+		outWidth, outHeight, _ := OutputSize(width, height, op)
+		w.stages = append(w.stages, newStage(op, width, height, outWidth, outHeight))
+		width, height = outWidth, outHeight
+	}
+	first := w.stages[0]
+	first.seen = make([]bool, len(first.src.Pix))
+	first.filled = make([]int, first.src.Height)
+	return w, nil
+}
+
+// Width returns the width of the output image.
+func (w *Window) Width() int {
+	return w.stages[len(w.stages)-1].width
+}
+
+// Height returns the height of the output image.
+func (w *Window) Height() int {
+	return len(w.stages[len(w.stages)-1].done)
+}
+
+// Set stores the source pixel at (x, y) and returns the output rows that
+// became ready, in increasing order. Pixels of a row that is already full
+// are ignored, so that the rows returned can be computed while more pixels
+// arrive.
+func (w *Window) Set(x, y int, c Color) ([]int, error) {
+	first := w.stages[0]
+	if x < 0 || y < 0 || x >= first.src.Width || y >= first.src.Height {
+		return nil, fmt.Errorf("pixel (%d, %d) is outside of the %dx%d image", x, y, first.src.Width, first.src.Height)
+	}
+	if first.filled[y] == first.src.Width {
+		// the row may already be read to compute the output
+		return nil, nil
+	}
+	i := y*first.src.Width + x
+	first.src.Pix[i] = c
+	if first.seen[i] {
+		return nil, nil
+	}
+	first.seen[i] = true
+	first.filled[y]++
+	if first.filled[y] < first.src.Width {
+		return nil, nil
+	}
+	return w.advance(0, first.fill(y)), nil
+}
+
+// advance computes the rows of stage i that became ready into the next
+// stage, and so on, returning the rows that became ready in the last one.
+func (w *Window) advance(i int, rows []int) []int {
+	for ; i+1 < len(w.stages) && len(rows) > 0; i++ {
+		cur, next := w.stages[i], w.stages[i+1]
+		for _, y := range rows {
+			cur.op.Row(cur.src, y, next.src.Row(y))
+		}
+		rows = next.fill(rows...)
+	}
+	if i+1 < len(w.stages) {
+		return nil
+	}
+	return rows
+}
+
+// Flush marks and returns every output row that has not been reported yet.
+// Pixels that never arrived are read as transparent black.
+func (w *Window) Flush() (res []int) {
+	for i, s := range w.stages {
+		rows := s.flush()
+		if i+1 < len(w.stages) {
+			rows = w.advance(i, rows)
+		}
+		res = append(res, rows...)
+	}
+	sort.Ints(res)
+	return
+}
+
+// Source returns row y of the source image as received by Set.
+func (w *Window) Source(y int) []Color {
+	return w.stages[0].src.Row(y)
+}
+
+// Row computes output row y into dst, which must hold a full row.
+func (w *Window) Row(y int, dst []Color) {
+	last := w.stages[len(w.stages)-1]
+	last.op.Row(last.src, y, dst)
+}
+
+// fill records that the source rows are full, then marks and returns the
+// output rows whose source rows all are, in increasing order.
+func (s *stage) fill(rows ...int) (res []int) {
+	for _, y := range rows {
+		s.full.add(y)
+		spans := s.waiting[y]
+		s.waiting[y] = nil
+		for _, i := range spans {
+			sp := s.spans[i]
+			if last := s.full.lastMissing(sp.hi); last >= sp.lo {
+				s.waiting[last] = append(s.waiting[last], i)
+				continue
+			}
+			for y := sp.first; y < sp.end; y++ {
+				if !s.done[y] {
+					s.done[y] = true
+					res = append(res, y)
+				}
+			}
+		}
+	}
+	sort.Ints(res)
+	return
+}
+
+// flush marks and returns every row that has not been reported yet.
+func (s *stage) flush() (res []int) {
+	for y, done := range s.done {
+		if !done {
+			s.done[y] = true
+			res = append(res, y)
+		}
+	}
+	return
+}
+
+// rowSet is a set of full rows, which finds the last row before a given one
+// that is not full in near constant time: entry y+1 leads towards it from
+// row y, entry 0 stands for none.
+type rowSet []int
+
+func newRowSet(height int) rowSet {
+	s := make(rowSet, height+1)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// add adds row y to s.
+func (s rowSet) add(y int) {
+	s[y+1] = y
+}
+
+// lastMissing returns the last row before hi that is not in s, -1 when
+// every one is.
+func (s rowSet) lastMissing(hi int) int {
+	i := hi
+	for s[i] != i {
+		s[i] = s[s[i]]
+		i = s[i]
+	}
+	return i - 1
+}
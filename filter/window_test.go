@@ -0,0 +1,224 @@
+package filter_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"nichowil/grpc-tutorial/filter"
+)
+
+const width, height = 23, 17
+
+// windowPipelines are run through windows and compared with Apply.
+var windowPipelines = []struct {
+	name  string
+	specs []filter.Spec
+}{
+	{"blur", []filter.Spec{{Name: "blur"}}},
+	{"blur wrapping around", []filter.Spec{{Name: "blur", Params: filter.Params{"radius": 3}, Edge: filter.Wrap}}},
+	{"sobel", []filter.Spec{{Name: "sobel", Edge: filter.Mirror}}},
+	{"pixelwise", []filter.Spec{{Name: "grayscale"}, {Name: "invert"}}},
+	{"blur between pixelwise steps", []filter.Spec{{Name: "grayscale"}, {Name: "blur"}, {Name: "invert"}, {Name: "sharpen"}}},
+	{"resize up", []filter.Spec{{Name: "resize", Params: filter.Params{"width": 41}}}},
+	{"resize down", []filter.Spec{{Name: "resize-lanczos", Params: filter.Params{"height": 6}}}},
+	{"rotate", []filter.Spec{{Name: "rotate"}}},
+	{"rotate by 30 degrees", []filter.Spec{{Name: "rotate", Params: filter.Params{"angle": 30}}}},
+	{"blur, resize and rotate", []filter.Spec{{Name: "blur"}, {Name: "resize", Params: filter.Params{"scale": 0.7}}, {Name: "rotate", Params: filter.Params{"angle": 270}}, {Name: "crop", Params: filter.Params{"x": 2, "y": 3, "width": 9, "height": 8}}}},
+}
+
+// pixel is a source pixel sent to a window.
+type pixel struct{ x, y int }
+
+// windowOrders return the order the pixels of an image are sent in.
+var windowOrders = []struct {
+	name  string
+	order func(r *rand.Rand) []pixel
+}{
+	{"rows", func(r *rand.Rand) (res []pixel) {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				res = append(res, pixel{x, y})
+			}
+		}
+		return
+	}},
+	{"columns", func(r *rand.Rand) (res []pixel) {
+		for x := 0; x < width; x++ {
+			for y := 0; y < height; y++ {
+				res = append(res, pixel{x, y})
+			}
+		}
+		return
+	}},
+	{"bottom up", func(r *rand.Rand) (res []pixel) {
+		for y := height - 1; y >= 0; y-- {
+			for x := 0; x < width; x++ {
+				res = append(res, pixel{x, y})
+			}
+		}
+		return
+	}},
+	{"random", randomOrder},
+	{"duplicates", func(r *rand.Rand) (res []pixel) {
+		for _, p := range randomOrder(r) {
+			res = append(res, p)
+			if r.Intn(2) == 0 {
+				res = append(res, res[r.Intn(len(res))])
+			}
+		}
+		return
+	}},
+}
+
+func randomOrder(r *rand.Rand) []pixel {
+	res := make([]pixel, 0, width*height)
+	for _, i := range r.Perm(width * height) {
+		res = append(res, pixel{i % width, i / width})
+	}
+	return res
+}
+
+func randomFrame(r *rand.Rand) *filter.Frame {
+	f := filter.NewFrame(width, height)
+	for i := range f.Pix {
+		f.Pix[i] = filter.Color{R: float32(r.Intn(256)), G: float32(r.Intn(256)), B: float32(r.Intn(256)), A: float32(r.Intn(256))}
+	}
+	return f
+}
+
+func newOps(t *testing.T, specs []filter.Spec) []filter.Op {
+	t.Helper()
+	var ops []filter.Op
+	for _, s := range specs {
+		op, err := filter.New(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// windowRun sends the pixels of src in order to a window running ops, which
+// must report every output row once, as soon as it can be computed: rows
+// are checked against want as they are reported, before more pixels
+// arrive. A row full of pixels sent again gets garbage, which the window
+// must ignore. It returns the rows reported by Flush.
+func windowRun(t *testing.T, src, want *filter.Frame, ops []filter.Op, order []pixel) []int {
+	t.Helper()
+	w, err := filter.NewWindow(width, height, ops...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Width() != want.Width || w.Height() != want.Height {
+		t.Fatalf("window of %dx%d pixels, want %dx%d", w.Width(), w.Height(), want.Width, want.Height)
+	}
+	reported := make([]bool, w.Height())
+	check := func(rows []int) {
+		t.Helper()
+		for i, y := range rows {
+			if i > 0 && rows[i-1] >= y {
+				t.Fatalf("rows %v are not in increasing order", rows)
+			}
+			if reported[y] {
+				t.Fatalf("row %d reported twice", y)
+			}
+			reported[y] = true
+			row := make([]filter.Color, w.Width())
+			w.Row(y, row)
+			for x, c := range row {
+				if c != want.Row(y)[x] {
+					t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, c, want.Row(y)[x])
+				}
+			}
+		}
+	}
+
+	sent, filled := make(map[pixel]bool), make([]int, height)
+	for _, p := range order {
+		c := src.Row(p.y)[p.x]
+		if filled[p.y] == width {
+			c = filter.Color{R: -1, G: -1, B: -1, A: -1}
+		}
+		if !sent[p] {
+			sent[p] = true
+			filled[p.y]++
+		}
+		rows, err := w.Set(p.x, p.y, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(rows)
+	}
+	rows := w.Flush()
+	check(rows)
+	for y, ok := range reported {
+		if !ok {
+			t.Errorf("row %d was never reported", y)
+		}
+	}
+	if rows := w.Flush(); len(rows) > 0 {
+		t.Errorf("rows %v reported again by a second Flush", rows)
+	}
+	return rows
+}
+
+func TestWindow(t *testing.T) {
+	for _, p := range windowPipelines {
+		for _, o := range windowOrders {
+			t.Run(fmt.Sprintf("%s/%s", p.name, o.name), func(t *testing.T) {
+				r := rand.New(rand.NewSource(1))
+				src := randomFrame(r)
+				ops := newOps(t, p.specs)
+				want, err := filter.Apply(src, ops...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rows := windowRun(t, src, want, ops, o.order(r)); len(rows) > 0 {
+					t.Errorf("rows %v were only reported by Flush", rows)
+				}
+			})
+		}
+	}
+}
+
+// TestWindowFlush leaves pixels out, which Flush computes as transparent
+// black.
+func TestWindowFlush(t *testing.T) {
+	for _, p := range windowPipelines {
+		t.Run(p.name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(1))
+			src := randomFrame(r)
+			var order []pixel
+			for _, px := range randomOrder(r) {
+				// a pixel of the middle row and a few random ones are missing
+				if px == (pixel{width / 2, height / 2}) || r.Intn(100) == 0 {
+					src.Row(px.y)[px.x] = filter.Color{}
+					continue
+				}
+				order = append(order, px)
+			}
+			ops := newOps(t, p.specs)
+			want, err := filter.Apply(src, ops...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows := windowRun(t, src, want, ops, order); len(rows) == 0 {
+				t.Error("Flush reported no row, while pixels are missing")
+			}
+		})
+	}
+}
+
+func TestWindowSetOutside(t *testing.T) {
+	w, err := filter.NewWindow(width, height, newOps(t, []filter.Spec{{Name: "blur"}})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []pixel{{-1, 0}, {0, -1}, {width, 0}, {0, height}} {
+		if _, err := w.Set(p.x, p.y, filter.Color{}); err == nil {
+			t.Errorf("Set(%d, %d) succeeded outside of the %dx%d image", p.x, p.y, width, height)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package integrity checks that the pixels of a Transform stream went
+// through it unchanged. Both ends tally the pixels they send and receive,
+// in whatever order they travel, and compare their tallies once the stream
+// ends.
+package integrity
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc64"
+	"image"
+
+	"nichowil/grpc-tutorial/filter"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var table = crc64.MakeTable(crc64.ECMA)
+
+// Tally counts pixels and sums their checksums, as described by pb.Tally.
+type Tally struct {
+	Pixels   int64
+	Checksum uint64
+}
+
+// Add adds the pixel at (x, y) whose RGBA bytes are rgba[:4].
+func (t *Tally) Add(x, y int, rgba []byte) {
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[0:], uint32(x))
+	binary.BigEndian.PutUint32(b[4:], uint32(y))
+	copy(b[8:], rgba[:4])
+	t.Pixels++
+	t.Checksum += crc64.Checksum(b[:], table)
+}
+
+// AddTile adds every pixel of tile.
+func (t *Tally) AddTile(tile *pb.Tile) {
+	width := int(tile.Width)
+	if width <= 0 {
+		return
+	}
+	x0, y0 := int(tile.Origin.GetX()), int(tile.Origin.GetY())
+	for i := 0; i+4 <= len(tile.Rgba); i += 4 {
+		p := i / 4
+		t.Add(x0+p%width, y0+p/width, tile.Rgba[i:])
+	}
+}
+
+// AddPixel adds p, its color rounded to 8 bits per channel.
+func (t *Tally) AddPixel(p *pb.Pixel) {
+	var rgba [4]byte
+	filter.PackRGBA(rgba[:], []filter.Color{filter.ColorFromProto(p.Color)})
+	t.Add(int(p.Point.GetX()), int(p.Point.GetY()), rgba[:])
+}
+
+// Proto returns t as a message.
+func (t Tally) Proto() *pb.Tally {
+	return &pb.Tally{Pixels: t.Pixels, Checksum: t.Checksum}
+}
+
+// FromProto returns the tally of a message, empty when m is nil.
+func FromProto(m *pb.Tally) Tally {
+	return Tally{Pixels: m.GetPixels(), Checksum: m.GetChecksum()}
+}
+
+func (t Tally) String() string {
+	return fmt.Sprintf("%d pixels with checksum %016x", t.Pixels, t.Checksum)
+}
+
+// Compare returns a codes.DataLoss error when the pixels of what, tallied
+// by both ends of a stream as want and got, differ.
+func Compare(what string, want, got Tally) error {
+	if want == got {
+		return nil
+	}
+	return status.Errorf(codes.DataLoss, "%s do not match: %v were expected, %v went through", what, want, got)
+}
+
+// Coverage records which pixels of a rectangle were received.
+type Coverage struct {
+	bounds image.Rectangle
+	seen   []bool
+	count  int
+}
+
+// NewCoverage returns the coverage of r, where no pixel was received yet.
+func NewCoverage(r image.Rectangle) *Coverage {
+	return &Coverage{bounds: r, seen: make([]bool, r.Dx()*r.Dy())}
+}
+
+// AddTile marks the pixels of tile as received. Those outside of the
+// rectangle are ignored.
+func (c *Coverage) AddTile(tile *pb.Tile) {
+	origin := image.Pt(int(tile.Origin.GetX()), int(tile.Origin.GetY()))
+	r := image.Rectangle{origin, origin.Add(image.Pt(int(tile.Width), int(tile.Height)))}.Intersect(c.bounds)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c.add(x, y)
+		}
+	}
+}
+
+// AddPixel marks the pixel at p as received, unless it is outside of the
+// rectangle.
+func (c *Coverage) AddPixel(p image.Point) {
+	if p.In(c.bounds) {
+		c.add(p.X, p.Y)
+	}
+}
+
+func (c *Coverage) add(x, y int) {
+	i := (y-c.bounds.Min.Y)*c.bounds.Dx() + x - c.bounds.Min.X
+	if !c.seen[i] {
+		c.seen[i] = true
+		c.count++
+	}
+}
+
+// Check returns a codes.DataLoss error naming the first pixel that was not
+// received, if any.
+func (c *Coverage) Check() error {
+	missing := len(c.seen) - c.count
+	if missing == 0 {
+		return nil
+	}
+	for i, seen := range c.seen {
+		if !seen {
+			x, y := c.bounds.Min.X+i%c.bounds.Dx(), c.bounds.Min.Y+i/c.bounds.Dx()
+			return status.Errorf(codes.DataLoss, "%d pixels of the result were not received, the first one at (%d, %d)", missing, x, y)
+		}
+	}
+	return nil
+}
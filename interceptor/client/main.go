@@ -14,6 +14,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"nichowil/grpc-tutorial/interceptor"
 	pb "nichowil/grpc-tutorial/transform"
 )
 
@@ -96,7 +97,8 @@ func vectorToImage(res [][]imageVector) (img image.Image) {
 
 func main() {
 	// Set up a connection to the server.
-	conn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(SimpleLogInterceptor), grpc.WithStreamInterceptor(StreamClientInterceptor))
+	logger := interceptor.NewLogger(os.Stderr)
+	conn, err := grpc.Dial("localhost:50051", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(logger.UnaryClientInterceptor()), grpc.WithStreamInterceptor(logger.StreamClientInterceptor()))
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -194,20 +196,3 @@ func main() {
 	newImage := vectorToImage(newImageV)
 	saveImageToFilePath(newImage, "images/result.jpg")
 }
-
-func SimpleLogInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	log.Println("Interceptor client : ", req)
-	err := invoker(ctx, method, req, reply, cc, opts...)
-	return err
-}
-
-func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	// Call 'streamer' to write messages to the stream before this function returns
-	log.Println("Interceptor stream client")
-	s, err := streamer(ctx, desc, cc, method, opts...)
-	if err != nil {
-		return nil, err
-	}
-
-	return s, err
-}
@@ -0,0 +1,229 @@
+// Package interceptor holds the interceptors shared by the servers and the
+// clients of the tutorial.
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Deadlines bounds how long the calls of a server may take. A call ends with
+// codes.DeadlineExceeded once its bound or the deadline of the client
+// passed, whichever comes first, and with codes.Canceled when the client
+// cancelled it.
+//
+// Handlers run on their own goroutine so that the call ends on time even
+// when the handler does not watch its context, or waits for a message from
+// a client that stopped sending; the goroutine exits as soon as the handler
+// returns. A stream that ends cancels the context of its handler, waits for
+// the message being sent, if any, and fails the messages and headers its
+// handler sends later, so that the handler stops using a stream grpc ended.
+// The message it is receiving fails as grpc ends the stream, and no message
+// is received nor sent once its context is done.
+type Deadlines struct {
+	// Unary and Stream bound the calls of the methods missing from
+	// Methods. Calls are not bounded when 0.
+	Unary, Stream time.Duration
+	// Methods bounds the calls of methods by full name, such as
+	// "/transform.Transform/SayHello". 0 lifts the bound of a method.
+	Methods map[string]time.Duration
+}
+
+// For returns the bound of the calls of method, which is a streaming method
+// when stream is set, 0 when they have none.
+func (d *Deadlines) For(method string, stream bool) time.Duration {
+	if t, ok := d.Methods[method]; ok {
+		return t
+	}
+	if stream {
+		return d.Stream
+	}
+	return d.Unary
+}
+
+// UnaryInterceptor returns the interceptor bounding unary calls.
+func (d *Deadlines) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := d.context(ctx, info.FullMethod, false)
+		defer cancel()
+
+		type result struct {
+			res interface{}
+			err error
+		}
+		// buffered so that the handler goroutine never blocks once the
+		// call ended without it
+		done := make(chan result, 1)
+		go func() {
+			res, err := handler(ctx, req)
+			done <- result{res, err}
+		}()
+		select {
+		case r := <-done:
+			if r.err != nil {
+				return nil, contextStatus(ctx, r.err)
+			}
+			return r.res, nil
+		case <-ctx.Done():
+			return nil, contextStatus(ctx, ctx.Err())
+		}
+	}
+}
+
+// StreamInterceptor returns the interceptor bounding streams.
+func (d *Deadlines) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := d.context(ss.Context(), info.FullMethod, true)
+		defer cancel()
+
+		bs := &boundedStream{ServerStream: ss, ctx: ctx}
+		defer func() {
+			cancel()
+			bs.close()
+		}()
+
+		// buffered so that the handler goroutine never blocks once the
+		// stream ended without it
+		done := make(chan error, 1)
+		go func() {
+			done <- handler(srv, bs)
+		}()
+		select {
+		case err := <-done:
+			return contextStatus(ctx, err)
+		case <-ctx.Done():
+			return contextStatus(ctx, ctx.Err())
+		}
+	}
+}
+
+// boundedContext is the context of a call bounded by the server, which
+// tells whether it ended because of the bound or the deadline of the client.
+type boundedContext struct {
+	context.Context
+	method string
+	bound  time.Duration
+	// own is set when the bound comes before the deadline of the client.
+	own bool
+}
+
+func (d *Deadlines) context(ctx context.Context, method string, stream bool) (context.Context, context.CancelFunc) {
+	bound := d.For(method, stream)
+	if bound <= 0 {
+		return context.WithCancel(ctx)
+	}
+	deadline := time.Now().Add(bound)
+	client, ok := ctx.Deadline()
+	own := !ok || deadline.Before(client)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return &boundedContext{Context: ctx, method: method, bound: bound, own: own}, cancel
+}
+
+// contextStatus returns err with the code telling why the call ended when
+// it ended because its context is done.
+func contextStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok && !isContextError(err) {
+		return err
+	}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		if b, ok := ctx.(*boundedContext); ok && b.own {
+			return status.Errorf(codes.DeadlineExceeded, "%s takes at most %v", b.method, b.bound)
+		}
+		return status.Error(codes.DeadlineExceeded, "the deadline of the call passed")
+	case ctx.Err() == context.Canceled:
+		return status.Error(codes.Canceled, "the call was cancelled")
+	case isContextError(err):
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// boundedStream is a stream whose context is bounded, which its handler
+// cannot use anymore once closed. Nothing is received or sent once its
+// context is done.
+//
+// Headers are set and messages sent holding mu, so that closing the stream
+// waits for them. Messages are received without it, or a handler waiting
+// for a message would keep its stream from ending: a message being received
+// as the stream is closed fails once grpc ended the stream.
+type boundedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// close makes the stream fail from now on, once its context is done.
+func (s *boundedStream) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// err returns the error of a stream that must not be used anymore, nil
+// when it may. mu must be held.
+func (s *boundedStream) err() error {
+	if s.closed || s.ctx.Err() != nil {
+		return contextStatus(s.ctx, context.Canceled)
+	}
+	return nil
+}
+
+// use runs f with the stream of grpc unless it must not be used anymore.
+func (s *boundedStream) use(f func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.err(); err != nil {
+		return err
+	}
+	return f()
+}
+
+func (s *boundedStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *boundedStream) SetHeader(md metadata.MD) error {
+	return s.use(func() error { return s.ServerStream.SetHeader(md) })
+}
+
+func (s *boundedStream) SendHeader(md metadata.MD) error {
+	return s.use(func() error { return s.ServerStream.SendHeader(md) })
+}
+
+func (s *boundedStream) SetTrailer(md metadata.MD) {
+	s.use(func() error {
+		s.ServerStream.SetTrailer(md)
+		return nil
+	})
+}
+
+func (s *boundedStream) SendMsg(m interface{}) error {
+	return s.use(func() error { return s.ServerStream.SendMsg(m) })
+}
+
+func (s *boundedStream) RecvMsg(m interface{}) error {
+	s.mu.Lock()
+	err := s.err()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.ServerStream.RecvMsg(m)
+}
@@ -0,0 +1,445 @@
+package interceptor_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nichowil/grpc-tutorial/interceptor"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	method = "/test.Test/Method"
+	bound  = 20 * time.Millisecond
+	// late bounds how long a call may take to end once it should have
+	late = time.Second
+)
+
+// waitGoroutines fails t unless the number of goroutines drops back to n.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	for end := time.Now().Add(late); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		if runtime.NumGoroutine() <= n {
+			return
+		}
+	}
+	t.Errorf("%d goroutines are left running, want %d", runtime.NumGoroutine(), n)
+}
+
+// checkStatus fails t unless err has code and a message containing msg.
+func checkStatus(t *testing.T, err error, code codes.Code, msg string) {
+	t.Helper()
+	st, _ := status.FromError(err)
+	if st.Code() != code || !strings.Contains(st.Message(), msg) {
+		t.Errorf("got %v, want code %v with a message containing %q", err, code, msg)
+	}
+}
+
+func TestUnaryDoesNotLeak(t *testing.T) {
+	base := runtime.NumGoroutine()
+	d := &interceptor.Deadlines{Unary: bound}
+	release := make(chan struct{})
+	// the handler does not watch its context
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-release
+		return "late", nil
+	}
+
+	start := time.Now()
+	res, err := d.UnaryInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	if elapsed := time.Since(start); elapsed > late {
+		t.Errorf("the call ended after %v, bounded to %v", elapsed, bound)
+	}
+	if res != nil {
+		t.Errorf("got result %v of a call that ran out of time", res)
+	}
+	checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+
+	close(release)
+	waitGoroutines(t, base)
+}
+
+// callCase is a call of method ending as the deadlines and the handler
+// tell.
+type callCase struct {
+	name      string
+	deadlines interceptor.Deadlines
+	// ctx returns the context of the client.
+	ctx func() (context.Context, context.CancelFunc)
+	// handler runs the call, given its context.
+	handler func(ctx context.Context) error
+	code    codes.Code
+	msg     string
+}
+
+func background() (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func clientTimeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), bound)
+}
+
+func clientCancel() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(bound, cancel)
+	return ctx, cancel
+}
+
+func waitDone(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+var callCases = []callCase{
+	{
+		name:      "own bound",
+		deadlines: interceptor.Deadlines{Unary: bound, Stream: bound},
+		ctx:       background,
+		handler:   waitDone,
+		code:      codes.DeadlineExceeded,
+		msg:       method + " takes at most 20ms",
+	},
+	{
+		name:      "bound of the method",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour, Methods: map[string]time.Duration{method: bound}},
+		ctx:       background,
+		handler:   waitDone,
+		code:      codes.DeadlineExceeded,
+		msg:       method + " takes at most 20ms",
+	},
+	{
+		name:      "bound lifted for the method",
+		deadlines: interceptor.Deadlines{Unary: time.Millisecond, Stream: time.Millisecond, Methods: map[string]time.Duration{method: 0}},
+		ctx:       background,
+		handler: func(ctx context.Context) error {
+			time.Sleep(bound)
+			return ctx.Err()
+		},
+		code: codes.OK,
+	},
+	{
+		name:      "client deadline first",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour},
+		ctx:       clientTimeout,
+		handler:   waitDone,
+		code:      codes.DeadlineExceeded,
+		msg:       "the deadline of the call passed",
+	},
+	{
+		name:    "client deadline without bound",
+		ctx:     clientTimeout,
+		handler: waitDone,
+		code:    codes.DeadlineExceeded,
+		msg:     "the deadline of the call passed",
+	},
+	{
+		name:      "cancelled",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour},
+		ctx:       clientCancel,
+		handler:   waitDone,
+		code:      codes.Canceled,
+		msg:       "the call was cancelled",
+	},
+	{
+		name:      "handler ignoring its context",
+		deadlines: interceptor.Deadlines{Unary: bound, Stream: bound},
+		ctx:       background,
+		handler: func(ctx context.Context) error {
+			time.Sleep(10 * bound)
+			return nil
+		},
+		code: codes.DeadlineExceeded,
+		msg:  method + " takes at most 20ms",
+	},
+	{
+		name:      "status of the handler",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour},
+		ctx:       background,
+		handler: func(ctx context.Context) error {
+			return status.Error(codes.NotFound, "no such image")
+		},
+		code: codes.NotFound,
+		msg:  "no such image",
+	},
+	{
+		name:      "context error of the handler",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour},
+		ctx:       background,
+		handler: func(ctx context.Context) error {
+			return context.Canceled
+		},
+		code: codes.Canceled,
+	},
+	{
+		name:      "success",
+		deadlines: interceptor.Deadlines{Unary: time.Hour, Stream: time.Hour},
+		ctx:       background,
+		handler:   func(ctx context.Context) error { return nil },
+		code:      codes.OK,
+	},
+}
+
+func TestUnaryCodes(t *testing.T) {
+	for _, c := range callCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := c.ctx()
+			defer cancel()
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := c.handler(ctx); err != nil {
+					return nil, err
+				}
+				return "done", nil
+			}
+			res, err := c.deadlines.UnaryInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			checkStatus(t, err, c.code, c.msg)
+			if c.code == codes.OK && res != "done" {
+				t.Errorf("got result %v, want done", res)
+			}
+		})
+	}
+}
+
+// fakeStream is a server stream whose messages wait until it is closed,
+// as grpc does once the handler returned.
+type fakeStream struct {
+	ctx    context.Context
+	closed chan struct{}
+}
+
+func (s *fakeStream) SetHeader(metadata.MD) error  { return nil }
+func (s *fakeStream) SendHeader(metadata.MD) error { return nil }
+func (s *fakeStream) SetTrailer(metadata.MD)       {}
+func (s *fakeStream) Context() context.Context     { return s.ctx }
+
+func (s *fakeStream) SendMsg(m interface{}) error {
+	<-s.closed
+	return errors.New("stream closed")
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	<-s.closed
+	return errors.New("stream closed")
+}
+
+func TestStreamCodes(t *testing.T) {
+	for _, c := range callCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			base := runtime.NumGoroutine()
+			ctx, cancel := c.ctx()
+			defer cancel()
+			ss := &fakeStream{ctx: ctx, closed: make(chan struct{})}
+			handler := func(srv interface{}, stream grpc.ServerStream) error {
+				return c.handler(stream.Context())
+			}
+			err := c.deadlines.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: method, IsClientStream: true, IsServerStream: true}, handler)
+			checkStatus(t, err, c.code, c.msg)
+			close(ss.closed)
+			cancel()
+			waitGoroutines(t, base)
+		})
+	}
+}
+
+func TestStreamDoesNotLeak(t *testing.T) {
+	base := runtime.NumGoroutine()
+	d := &interceptor.Deadlines{Stream: bound}
+	ss := &fakeStream{ctx: context.Background(), closed: make(chan struct{})}
+	returned := make(chan error, 1)
+	// the handler waits for a message that never comes
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		err := stream.RecvMsg(new(pb.Tile))
+		returned <- err
+		return err
+	}
+
+	start := time.Now()
+	err := d.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: method, IsClientStream: true}, handler)
+	if elapsed := time.Since(start); elapsed > late {
+		t.Errorf("the stream ended after %v, bounded to %v", elapsed, bound)
+	}
+	checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+
+	// grpc closes the stream once the interceptor returned
+	close(ss.closed)
+	select {
+	case <-returned:
+	case <-time.After(late):
+		t.Fatal("the handler is still waiting for a message")
+	}
+	waitGoroutines(t, base)
+
+	// nothing is received once the stream ran out of time
+	ss = &fakeStream{ctx: context.Background(), closed: make(chan struct{})}
+	err = d.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: method, IsClientStream: true}, func(srv interface{}, stream grpc.ServerStream) error {
+		<-stream.Context().Done()
+		err := stream.RecvMsg(new(pb.Tile))
+		returned <- err
+		return err
+	})
+	checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+	select {
+	case err := <-returned:
+		checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+	case <-time.After(late):
+		t.Fatal("receiving a message waited for the stream once it ran out of time")
+	}
+}
+
+// sendStream is a server stream whose first message is sent once release
+// is closed. Using it after ended is set fails the test.
+type sendStream struct {
+	fakeStream
+	t       *testing.T
+	release chan struct{}
+	sent    int32
+	ended   int32
+}
+
+func (s *sendStream) use(what string) {
+	if atomic.LoadInt32(&s.ended) != 0 {
+		s.t.Errorf("%s after the interceptor returned", what)
+	}
+}
+
+func (s *sendStream) SetHeader(metadata.MD) error {
+	s.use("header set")
+	return nil
+}
+
+func (s *sendStream) SendHeader(metadata.MD) error {
+	s.use("header sent")
+	return nil
+}
+
+func (s *sendStream) SetTrailer(metadata.MD) {
+	s.use("trailer set")
+}
+
+func (s *sendStream) SendMsg(m interface{}) error {
+	s.use("message sent")
+	if atomic.AddInt32(&s.sent, 1) == 1 {
+		<-s.release
+	}
+	return nil
+}
+
+// TestStreamBoundDuringSend ends a stream whose bound passes while its
+// handler sends a message, once the message is sent, and fails what the
+// handler sends later.
+func TestStreamBoundDuringSend(t *testing.T) {
+	d := &interceptor.Deadlines{Stream: bound}
+	ss := &sendStream{fakeStream: fakeStream{ctx: context.Background(), closed: make(chan struct{})}, t: t, release: make(chan struct{})}
+	returned := make(chan error, 1)
+	// the handler ignores its context and sends until sending fails
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			if err := stream.SendMsg(new(pb.Tile)); err != nil {
+				stream.SetTrailer(metadata.Pairs("k", "v"))
+				if err := stream.SendHeader(nil); err == nil {
+					t.Error("header sent once the stream ended")
+				}
+				returned <- err
+				return err
+			}
+		}
+	}
+
+	ended := make(chan error, 1)
+	go func() {
+		ended <- d.StreamInterceptor()(nil, ss, &grpc.StreamServerInfo{FullMethod: method, IsServerStream: true}, handler)
+	}()
+	select {
+	case err := <-ended:
+		t.Fatalf("the stream ended with %v while a message was being sent", err)
+	case <-time.After(5 * bound):
+	}
+	close(ss.release)
+	var err error
+	select {
+	case err = <-ended:
+	case <-time.After(late):
+		t.Fatal("the stream did not end once the message was sent")
+	}
+	atomic.StoreInt32(&ss.ended, 1)
+	checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+
+	select {
+	case err := <-returned:
+		checkStatus(t, err, codes.DeadlineExceeded, method+" takes at most")
+	case <-time.After(late):
+		t.Fatal("the handler is still sending")
+	}
+	if n := atomic.LoadInt32(&ss.sent); n != 1 {
+		t.Errorf("%d messages sent, want the one being sent as the bound passed", n)
+	}
+}
+
+// tilesServer reads the tiles of TransformTiles streams until receiving
+// fails, which it reports to returned.
+type tilesServer struct {
+	pb.UnimplementedTransformServer
+	returned chan error
+}
+
+func (s *tilesServer) TransformTiles(stream pb.Transform_TransformTilesServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			s.returned <- err
+			return err
+		}
+	}
+}
+
+// TestStreamBlockedInRecv ends, on a real server, a stream whose handler
+// waits for a tile from a client that never sends one.
+func TestStreamBlockedInRecv(t *testing.T) {
+	d := &interceptor.Deadlines{Methods: map[string]time.Duration{"/transform.Transform/TransformTiles": bound}}
+	lis := bufconn.Listen(1 << 20)
+	srv := &tilesServer{returned: make(chan error, 1)}
+	s := grpc.NewServer(grpc.StreamInterceptor(d.StreamInterceptor()))
+	pb.RegisterTransformServer(s, srv)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	stream, err := pb.NewTransformClient(conn).TransformTiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if elapsed := time.Since(start); elapsed > late {
+		t.Errorf("the stream ended after %v, bounded to %v", elapsed, bound)
+	}
+	checkStatus(t, err, codes.DeadlineExceeded, "/transform.Transform/TransformTiles takes at most 20ms")
+
+	select {
+	case <-srv.returned:
+	case <-time.After(late):
+		t.Fatal("the handler is still waiting for a tile")
+	}
+}
@@ -0,0 +1,262 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataRequestID is the metadata key carrying the ID of a request. The
+// client interceptors set it unless the call already has one, and the
+// server interceptors send it back in the response header, making one up
+// when the client did not send it.
+const MetadataRequestID = "x-request-id"
+
+// Redacted replaces the values of secret metadata keys in the log.
+const Redacted = "[redacted]"
+
+// secretKeys are the metadata keys whose values are never logged, along
+// with those holding one of secretWords.
+var (
+	secretKeys  = map[string]bool{"authorization": true, "proxy-authorization": true, "cookie": true, "set-cookie": true}
+	secretWords = []string{"token", "secret", "password", "api-key", "apikey"}
+)
+
+// Secret reports whether the values of the metadata key must not be logged.
+func Secret(key string) bool {
+	key = strings.ToLower(key)
+	if secretKeys[key] {
+		return true
+	}
+	for _, w := range secretWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Call is the line logged for a call.
+type Call struct {
+	Time time.Time `json:"time"`
+	// Side is "server" or "client".
+	Side      string `json:"side"`
+	Method    string `json:"method"`
+	Peer      string `json:"peer,omitempty"`
+	RequestID string `json:"request_id"`
+	// TraceID is the trace of the call when it is traced.
+	TraceID   string  `json:"trace_id,omitempty"`
+	Code      string  `json:"code"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	// RequestBytes and ResponseBytes sum the sizes of the messages sent
+	// by the client and by the server.
+	RequestBytes  int64 `json:"request_bytes"`
+	ResponseBytes int64 `json:"response_bytes"`
+	// Requests and Responses count the messages of streams.
+	Requests  int64 `json:"requests,omitempty"`
+	Responses int64 `json:"responses,omitempty"`
+	// Metadata is the metadata of the request, secret values redacted.
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// Logger logs every call as a line of JSON. It is safe for concurrent use.
+type Logger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewLogger returns a logger writing to w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{enc: json.NewEncoder(w)}
+}
+
+func (l *Logger) log(c *Call) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(c)
+}
+
+// start returns the call of method made with ctx, for side, before it
+// runs.
+func start(ctx context.Context, side, method string, md metadata.MD) *Call {
+	c := &Call{Time: time.Now(), Side: side, Method: method}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		c.TraceID = sc.TraceID().String()
+	}
+	if ids := md.Get(MetadataRequestID); len(ids) > 0 {
+		c.RequestID = ids[0]
+	}
+	if len(md) > 0 {
+		c.Metadata = make(map[string][]string, len(md))
+		for k, v := range md {
+			if Secret(k) {
+				v = []string{Redacted}
+			}
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
+
+// end completes c once the call ended with err.
+func (c *Call) end(err error) *Call {
+	c.LatencyMS = float64(time.Since(c.Time)) / float64(time.Millisecond)
+	st := status.Convert(err)
+	c.Code = st.Code().String()
+	if err != nil {
+		c.Error = st.Message()
+	}
+	return c
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func size(m interface{}) int64 {
+	if m, ok := m.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+func peerAddr(p *peer.Peer) string {
+	if p == nil || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
+}
+
+// serverCall starts the call of method received with ctx, and sends its
+// request ID back in the header with setHeader.
+func serverCall(ctx context.Context, method string, setHeader func(metadata.MD) error) *Call {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c := start(ctx, "server", method, md)
+	if c.RequestID == "" {
+		c.RequestID = newRequestID()
+	}
+	p, _ := peer.FromContext(ctx)
+	c.Peer = peerAddr(p)
+	setHeader(metadata.Pairs(MetadataRequestID, c.RequestID))
+	return c
+}
+
+// UnaryServerInterceptor returns the interceptor logging unary calls on a
+// server.
+func (l *Logger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := serverCall(ctx, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+		res, err := handler(ctx, req)
+		c.RequestBytes = size(req)
+		if err == nil {
+			c.ResponseBytes = size(res)
+		}
+		l.log(c.end(err))
+		return res, err
+	}
+}
+
+// StreamServerInterceptor returns the interceptor logging streams on a
+// server.
+func (l *Logger) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := serverCall(ss.Context(), info.FullMethod, ss.SetHeader)
+		var recv, sent counter
+		err := handler(srv, &observedServerStream{ServerStream: ss, recv: recv.add, sent: sent.add})
+		c.count(&recv, &sent)
+		l.log(c.end(err))
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns the interceptor logging unary calls of a
+// client.
+func (l *Logger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, c := clientCall(ctx, method)
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+		c.Peer = peerAddr(&p)
+		c.RequestBytes = size(req)
+		if err == nil {
+			c.ResponseBytes = size(reply)
+		}
+		l.log(c.end(err))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns the interceptor logging streams of a
+// client. A stream is logged once it was read to the end, or once its
+// response was received when the server sends only one.
+func (l *Logger) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, c := clientCall(ctx, method)
+		var (
+			p          peer.Peer
+			recv, sent counter
+		)
+		cs, err := streamer(ctx, desc, cc, method, append(opts, grpc.Peer(&p))...)
+		if err != nil {
+			l.log(c.end(err))
+			return nil, err
+		}
+		return &observedClientStream{
+			ClientStream: cs,
+			recv:         recv.add,
+			sent:         sent.add,
+			single:       !desc.ServerStreams,
+			end: func(err error) {
+				c.Peer = peerAddr(&p)
+				c.count(&sent, &recv)
+				l.log(c.end(err))
+			},
+		}, nil
+	}
+}
+
+// clientCall starts the call of method made with ctx, adding a request ID
+// to its metadata unless it has one.
+func clientCall(ctx context.Context, method string) (context.Context, *Call) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get(MetadataRequestID)) == 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, newRequestID())
+		md, _ = metadata.FromOutgoingContext(ctx)
+	}
+	return ctx, start(ctx, "client", method, md)
+}
+
+// counter counts the messages going one way, and their bytes. Messages go
+// each way on a single goroutine at a time, but the totals are read from
+// another.
+type counter struct {
+	messages, bytes int64
+}
+
+func (c *counter) add(m interface{}) {
+	atomic.AddInt64(&c.messages, 1)
+	atomic.AddInt64(&c.bytes, size(m))
+}
+
+// count sets the messages of the stream of c, received and sent by the
+// client in requests and by the server in responses.
+func (c *Call) count(requests, responses *counter) {
+	c.Requests, c.RequestBytes = atomic.LoadInt64(&requests.messages), atomic.LoadInt64(&requests.bytes)
+	c.Responses, c.ResponseBytes = atomic.LoadInt64(&responses.messages), atomic.LoadInt64(&responses.bytes)
+}
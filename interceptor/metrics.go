@@ -0,0 +1,153 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"nichowil/grpc-tutorial/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics records the calls of a server or a client in a registry, under
+// the names used by the usual gRPC dashboards:
+//
+//	grpc_{server,client}_started_total       calls started
+//	grpc_{server,client}_handled_total       calls ended, by status code
+//	grpc_{server,client}_handling_seconds    latency of the calls
+//	grpc_{server,client}_in_flight           calls running
+//	grpc_{server,client}_msg_received_total  messages received on streams
+//	grpc_{server,client}_msg_sent_total      messages sent on streams
+//
+// Every metric is labelled with grpc_type (unary, client_stream,
+// server_stream or bidi_stream), grpc_service and grpc_method.
+type Metrics struct {
+	server, client *sideMetrics
+}
+
+// sideMetrics are the metrics of the server or of the client side.
+type sideMetrics struct {
+	started, handled *metrics.Counter
+	handling         *metrics.Histogram
+	inFlight         *metrics.Gauge
+	msgRecv, msgSent *metrics.Counter
+}
+
+// NewMetrics registers the metrics of calls in r. Those of a side are only
+// exposed once one of its interceptors recorded a call.
+func NewMetrics(r *metrics.Registry) *Metrics {
+	return &Metrics{server: newSideMetrics(r, "server"), client: newSideMetrics(r, "client")}
+}
+
+func newSideMetrics(r *metrics.Registry, side string) *sideMetrics {
+	prefix := "grpc_" + side + "_"
+	return &sideMetrics{
+		started:  r.Counter(prefix+"started_total", "Number of calls started on the "+side+".", "grpc_type", "grpc_service", "grpc_method"),
+		handled:  r.Counter(prefix+"handled_total", "Number of calls ended on the "+side+", by status code.", "grpc_type", "grpc_service", "grpc_method", "grpc_code"),
+		handling: r.Histogram(prefix+"handling_seconds", "Latency of the calls ended on the "+side+".", metrics.DefaultBuckets, "grpc_type", "grpc_service", "grpc_method"),
+		inFlight: r.Gauge(prefix+"in_flight", "Number of calls running on the "+side+".", "grpc_type", "grpc_service", "grpc_method"),
+		msgRecv:  r.Counter(prefix+"msg_received_total", "Number of messages received by the "+side+" on streams.", "grpc_type", "grpc_service", "grpc_method"),
+		msgSent:  r.Counter(prefix+"msg_sent_total", "Number of messages sent by the "+side+" on streams.", "grpc_type", "grpc_service", "grpc_method"),
+	}
+}
+
+// rpcType returns the grpc_type of a method streaming as told.
+func rpcType(clientStream, serverStream bool) string {
+	switch {
+	case clientStream && serverStream:
+		return "bidi_stream"
+	case clientStream:
+		return "client_stream"
+	case serverStream:
+		return "server_stream"
+	}
+	return "unary"
+}
+
+// call is a call being recorded.
+type call struct {
+	m        *sideMetrics
+	labels   []string
+	inFlight *metrics.GaugeValue
+	start    time.Time
+}
+
+// start records the start of a call of fullMethod, of type typ.
+func (m *sideMetrics) start(typ, fullMethod string) *call {
+	service, method := "unknown", "unknown"
+	if i := strings.LastIndex(fullMethod, "/"); i > 0 {
+		service, method = strings.TrimPrefix(fullMethod[:i], "/"), fullMethod[i+1:]
+	}
+	c := &call{m: m, labels: []string{typ, service, method}, start: time.Now()}
+	c.inFlight = m.inFlight.With(c.labels...)
+	c.inFlight.Add(1)
+	m.started.With(c.labels...).Inc()
+	return c
+}
+
+// end records the end of c with err.
+func (c *call) end(err error) {
+	c.inFlight.Add(-1)
+	c.m.handled.With(append(c.labels, status.Code(err).String())...).Inc()
+	c.m.handling.With(c.labels...).Observe(time.Since(c.start).Seconds())
+}
+
+// counters returns the functions counting the messages received and sent
+// on the stream of c.
+func (c *call) counters() (recv, sent func(interface{})) {
+	r, s := c.m.msgRecv.With(c.labels...), c.m.msgSent.With(c.labels...)
+	return func(interface{}) { r.Inc() }, func(interface{}) { s.Inc() }
+}
+
+// UnaryServerInterceptor returns the interceptor recording unary calls on a
+// server.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := m.server.start(rpcType(false, false), info.FullMethod)
+		res, err := handler(ctx, req)
+		c.end(err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor returns the interceptor recording streams on a
+// server.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		c := m.server.start(rpcType(info.IsClientStream, info.IsServerStream), info.FullMethod)
+		recv, sent := c.counters()
+		err := handler(srv, &observedServerStream{ServerStream: ss, recv: recv, sent: sent})
+		c.end(err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns the interceptor recording unary calls of a
+// client.
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		c := m.client.start(rpcType(false, false), method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		c.end(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns the interceptor recording streams of a
+// client. A stream ends once it was read to the end, or once its response
+// was received when the server sends only one; the streams a client gives
+// up on before stay in flight.
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		c := m.client.start(rpcType(desc.ClientStreams, desc.ServerStreams), method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.end(err)
+			return nil, err
+		}
+		recv, sent := c.counters()
+		return &observedClientStream{ClientStream: cs, recv: recv, sent: sent, end: c.end, single: !desc.ServerStreams}, nil
+	}
+}
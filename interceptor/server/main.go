@@ -7,19 +7,26 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/interceptor"
+	"nichowil/grpc-tutorial/tracing"
 	pb "nichowil/grpc-tutorial/transform"
 
 	"google.golang.org/grpc/status"
 )
 
 var (
-	port = flag.Int("port", 50051, "The server port")
+	port    = flag.Int("port", 50051, "The server port")
+	timeout = flag.Duration("timeout", 3*time.Second, "How long unary calls may take, 0 does not bound them")
+	traceTo = flag.String("trace", "", "Where the spans of the calls are exported: stdout, stderr, file:PATH, otlp or the URL of an OTLP collector, nowhere when empty")
 )
 
 type server struct {
@@ -46,7 +53,7 @@ func (s *server) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloRe
 }
 
 func (s *server) Transform(stream pb.Transform_TransformServer) error {
-	//var imageVector [][]pb.Color
+	var apply filter.Func
 	for {
 		pixel, err := stream.Recv()
 		if err == io.EOF {
@@ -55,9 +62,22 @@ func (s *server) Transform(stream pb.Transform_TransformServer) error {
 		if err != nil {
 			return err
 		}
+		if pixel.GetColor() == nil {
+			return status.Error(codes.InvalidArgument, "pixel color is missing")
+		}
+		if apply == nil {
+			ops, err := filter.Select(stream.Context(), pixel.Pipeline)
+			if err != nil {
+				return err
+			}
+			ops = filter.Fuse(ops)
+			var ok bool
+			if apply, ok = ops[0].(filter.Func); !ok || len(ops) > 1 {
+				return status.Error(codes.Unimplemented, "filters reading neighbouring pixels are only served by the complete server")
+			}
+		}
 
-		// do smth to pixel here
-		pixel.Color.R = 0
+		filter.ApplyPixel(apply, pixel.Color)
 
 		stream.Send(pixel)
 	}
@@ -69,69 +89,43 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	s := grpc.NewServer(grpc.StreamInterceptor(StreamServerInterceptor), grpc.UnaryInterceptor(UnaryServerTimeoutInterceptor))
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	if *traceTo != "" {
+		tracer, err := tracing.NewProvider("interceptor-server", *traceTo)
+		if err != nil {
+			log.Fatalf("failed to set up tracing: %v", err)
+		}
+		defer tracer.Close()
+		t := interceptor.NewTracing(tracer)
+		unary = append(unary, t.UnaryServerInterceptor())
+		stream = append(stream, t.StreamServerInterceptor())
+	}
+	deadlines := &interceptor.Deadlines{Unary: *timeout}
+	logger := interceptor.NewLogger(os.Stderr)
+	unary = append(unary, logger.UnaryServerInterceptor(), deadlines.UnaryInterceptor())
+	stream = append(stream, logger.StreamServerInterceptor(), deadlines.StreamInterceptor())
+	s := grpc.NewServer(grpc.ChainStreamInterceptor(stream...), grpc.ChainUnaryInterceptor(unary...))
 	pb.RegisterTransformServer(s, &server{})
+
+	// stopping lets the calls in progress end, for a while, then returns
+	// from Serve so that closing the provider exports the spans left
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Printf("stopping once the calls in progress end, at most %v", shutdownGrace)
+		time.AfterFunc(shutdownGrace, s.Stop)
+		s.GracefulStop()
+	}()
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
 
-func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	log.Println("[Intercept request] : pre request interceptor")
-
-	err := handler(srv, ss)
-	if err != nil {
-		return err
-	}
-
-	log.Println("[Intercept request] : post request interceptor")
-	return nil
-}
-
-func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	// Invoke 'handler' to use your gRPC server implementation and get
-	// the response.
-	log.Println("[Intercept request] : pre request interceptor")
-
-	// Get the metadata from the incoming context
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, fmt.Errorf("couldn't parse incoming context metadata")
-	}
-	log.Println("metadata : ", md)
-
-	h, err := handler(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-
-	log.Println("[Intercept request] : post request interceptor")
-	return h, err
-}
-
-func UnaryServerTimeoutInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	var err error
-	var result interface{}
-
-	log.Println("[Intercept request] : pre request interceptor")
-
-	done := make(chan struct{})
-
-	go func() {
-		result, err = handler(ctx, req)
-		done <- struct{}{}
-	}()
-
-	select {
-	case <-ctx.Done():
-		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
-			log.Println("context timed out")
-			return nil, status.New(codes.Canceled, "Client cancelled, abandoning.").Err()
-		}
-	case <-done:
-	}
-
-	log.Println("[Intercept request] : post request interceptor")
-	return result, err
-}
+// shutdownGrace bounds how long a stopping server waits for the calls in
+// progress.
+const shutdownGrace = 10 * time.Second
@@ -0,0 +1,72 @@
+package interceptor
+
+import (
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// observedServerStream reports the messages received and sent on a server
+// stream.
+type observedServerStream struct {
+	grpc.ServerStream
+	recv, sent func(m interface{})
+}
+
+func (s *observedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recv(m)
+	}
+	return err
+}
+
+func (s *observedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	}
+	return err
+}
+
+// observedClientStream reports the messages received and sent on a client
+// stream, and its end once. A stream ends once it was read to the end, or
+// once its response was received when the server sends only one; streams
+// the client gives up on before are not reported as ended.
+type observedClientStream struct {
+	grpc.ClientStream
+	recv, sent func(m interface{})
+	end        func(err error)
+	// single is set when the server sends a single message.
+	single bool
+	once   sync.Once
+}
+
+func (s *observedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sent(m)
+	}
+	return err
+}
+
+func (s *observedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.recv(m)
+		if s.single {
+			s.finish(nil)
+		}
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *observedClientStream) finish(err error) {
+	s.once.Do(func() { s.end(err) })
+}
@@ -0,0 +1,185 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"nichowil/grpc-tutorial/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Tracing traces every call in a span, a child of the span of the caller
+// passed in the traceparent and tracestate metadata of the W3C trace
+// context. The server interceptors make the span of a call the span of the
+// context of its handler, for it to trace its own operations with
+// tracing.Start; the client interceptors pass the span of a call on to the
+// server.
+type Tracing struct {
+	tracer trace.Tracer
+}
+
+// NewTracing returns the interceptors starting spans with tp.
+func NewTracing(tp trace.TracerProvider) *Tracing {
+	return &Tracing{tracer: tp.Tracer(tracing.Scope)}
+}
+
+// traceContext reads and writes the W3C trace context.
+var traceContext propagation.TraceContext
+
+// metadataCarrier carries the trace context in gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	// repeated headers are joined, as in HTTP
+	return strings.Join(metadata.MD(c).Get(key), ",")
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// rpcAttributes returns the attributes describing a call of fullMethod.
+func rpcAttributes(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	attrs := []attribute.KeyValue{attribute.String("rpc.system", "grpc")}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		attrs = append(attrs, attribute.String("rpc.service", name[:i]), attribute.String("rpc.method", name[i+1:]))
+	}
+	return name, attrs
+}
+
+// endSpan ends the span of a call that ended with err.
+func endSpan(span trace.Span, err error) {
+	st := status.Convert(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, st.Message())
+	}
+	span.End()
+}
+
+// countMessages adds the numbers of messages of a stream to span.
+func countMessages(span trace.Span, recv, sent *counter) {
+	span.SetAttributes(
+		attribute.Int64("rpc.messages_received", atomic.LoadInt64(&recv.messages)),
+		attribute.Int64("rpc.messages_sent", atomic.LoadInt64(&sent.messages)),
+	)
+}
+
+// serverSpan starts the span of a call of fullMethod received with ctx.
+func (t *Tracing) serverSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	// a malformed traceparent starts a new trace
+	ctx = traceContext.Extract(ctx, metadataCarrier(md))
+	name, attrs := rpcAttributes(fullMethod)
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, attribute.String("net.peer.address", peerAddr(p)))
+	}
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// UnaryServerInterceptor returns the interceptor tracing unary calls on a
+// server.
+func (t *Tracing) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := t.serverSpan(ctx, info.FullMethod)
+		res, err := handler(ctx, req)
+		endSpan(span, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor returns the interceptor tracing streams on a
+// server.
+func (t *Tracing) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := t.serverSpan(ss.Context(), info.FullMethod)
+		var recv, sent counter
+		err := handler(srv, &observedServerStream{
+			ServerStream: &contextStream{ServerStream: ss, ctx: ctx},
+			recv:         recv.add,
+			sent:         sent.add,
+		})
+		countMessages(span, &recv, &sent)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// contextStream is a server stream with another context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientSpan starts the span of a call of fullMethod made with ctx, and
+// passes it on in the metadata of the call.
+func (t *Tracing) clientSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	name, attrs := rpcAttributes(fullMethod)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	md := metadata.MD{}
+	traceContext.Inject(ctx, metadataCarrier(md))
+	var kv []string
+	for k, v := range md {
+		kv = append(kv, k, v[0])
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...), span
+}
+
+// UnaryClientInterceptor returns the interceptor tracing unary calls of a
+// client.
+func (t *Tracing) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := t.clientSpan(ctx, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns the interceptor tracing streams of a
+// client. The span of a stream ends once it was read to the end, or once
+// its response was received when the server sends only one; the spans of
+// the streams a client gives up on before are not exported.
+func (t *Tracing) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := t.clientSpan(ctx, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endSpan(span, err)
+			return nil, err
+		}
+		var recv, sent counter
+		return &observedClientStream{
+			ClientStream: cs,
+			recv:         recv.add,
+			sent:         sent.add,
+			single:       !desc.ServerStreams,
+			end: func(err error) {
+				countMessages(span, &recv, &sent)
+				endSpan(span, err)
+			},
+		}, nil
+	}
+}
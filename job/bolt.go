@@ -0,0 +1,131 @@
+package job
+
+import (
+	"time"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Buckets of a bolt store, each keyed by job ID.
+var (
+	jobsBucket    = []byte("jobs")
+	inputsBucket  = []byte("inputs")
+	resultsBucket = []byte("results")
+)
+
+// boltStore is a Store in a bolt database file, holding every value as an
+// encoded protocol buffer message.
+type boltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens the bolt database at path as a Store, creating it when
+// it does not exist. The file is locked until the Store is closed.
+func OpenBolt(path string) (Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, inputsBucket, resultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Jobs() (res []*pb.Job, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			info := &pb.Job{}
+			if err := proto.Unmarshal(v, info); err != nil {
+				return err
+			}
+			res = append(res, info)
+			return nil
+		})
+	})
+	return
+}
+
+func (s *boltStore) Save(info *pb.Job) error {
+	return s.put(jobsBucket, info.Id, info)
+}
+
+func (s *boltStore) SaveInput(id string, in *pb.ImageChunk) error {
+	return s.put(inputsBucket, id, in)
+}
+
+func (s *boltStore) Input(id string) (*pb.ImageChunk, error) {
+	in := &pb.ImageChunk{}
+	if err := s.get(inputsBucket, id, in); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func (s *boltStore) SaveResult(id string, res *pb.ImageResponse) error {
+	return s.put(resultsBucket, id, res)
+}
+
+func (s *boltStore) Result(id string) (*pb.ImageResponse, error) {
+	res := &pb.ImageResponse{}
+	if err := s.get(resultsBucket, id, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *boltStore) DeleteInput(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(inputsBucket).Delete([]byte(id))
+	})
+}
+
+func (s *boltStore) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, inputsBucket, resultsBucket} {
+			if err := tx.Bucket(name).Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *boltStore) put(bucket []byte, id string, m proto.Message) error {
+	v, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(id), v)
+	})
+}
+
+// get decodes the value of id into m, reporting ErrNotFound when there is
+// none.
+func (s *boltStore) get(bucket []byte, id string, m proto.Message) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucket).Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		// v is only valid during the transaction, Unmarshal copies it
+		return proto.Unmarshal(v, m)
+	})
+}
@@ -0,0 +1,374 @@
+// Package job runs transforms in the background, on a bounded pool of
+// workers, independently of the connection of the client that submitted
+// them.
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrNotFound reports a job that does not exist or was forgotten.
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull reports a job submitted while every slot of the queue is
+	// taken.
+	ErrQueueFull = errors.New("too many jobs are waiting to run")
+	// ErrNotDone reports a result asked for before the job is done.
+	ErrNotDone = errors.New("job is not done")
+	// ErrClosed reports a job submitted to a closed Manager.
+	ErrClosed = errors.New("jobs are no longer accepted")
+)
+
+// Func transforms the input of a job, reporting the fraction of the work
+// done to progress. It must return soon after ctx is done.
+type Func func(ctx context.Context, in *pb.ImageChunk, progress func(float64)) (*pb.ImageResponse, error)
+
+// job is the state of a submitted job, guarded by the mutex of its Manager.
+// Its input and result are kept by the Store.
+type job struct {
+	info   *pb.Job
+	cancel context.CancelFunc // while the job runs
+	// changed is closed and replaced every time info changes.
+	changed chan struct{}
+}
+
+// Manager queues jobs and runs them on a fixed number of workers.
+type Manager struct {
+	run       Func
+	store     Store
+	retention time.Duration
+	queue     chan *job
+	ctx       context.Context
+	stop      context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+}
+
+// NewManager starts workers running jobs with run. At most queueSize jobs
+// wait for a worker, and jobs that ended are forgotten after retention.
+// Jobs are kept in store, a memory store when nil. The jobs of store that
+// had not ended are queued again, from the start, in the order they were
+// submitted.
+func NewManager(workers, queueSize int, retention time.Duration, store Store, run Func) (*Manager, error) {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	infos, err := store.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Created < infos[j].Created })
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		run:       run,
+		store:     store,
+		retention: retention,
+		queue:     make(chan *job, queueSize+len(infos)),
+		ctx:       ctx,
+		stop:      stop,
+		jobs:      make(map[string]*job),
+	}
+	for _, info := range infos {
+		j := &job{info: info, changed: make(chan struct{})}
+		if !ended(info.State) {
+			info.State, info.Progress = pb.Job_QUEUED, 0
+			if err := store.Save(info); err != nil {
+				stop()
+				return nil, err
+			}
+			m.queue <- j
+		}
+		m.jobs[info.Id] = j
+	}
+
+	m.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go m.work()
+	}
+	go m.expire()
+	return m, nil
+}
+
+// Close cancels the jobs that are running, stops the workers and waits for
+// them to return. Jobs still queued never run, they are resumed by the next
+// Manager using a persistent Store. The Store is not closed.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.stop()
+	m.wg.Wait()
+}
+
+// Submit queues a job transforming in and returns its state.
+func (m *Manager) Submit(in *pb.ImageChunk) (*pb.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	j := &job{
+		info:    &pb.Job{Id: id, State: pb.Job_QUEUED, Created: time.Now().Unix()},
+		changed: make(chan struct{}),
+	}
+	// stored before taking the lock, an input may be large
+	if err := m.store.SaveInput(id, in); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		m.forget(id)
+		return nil, ErrClosed
+	}
+	if err := m.store.Save(j.info); err != nil {
+		m.forget(id)
+		return nil, err
+	}
+	select {
+	case m.queue <- j:
+	default:
+		m.forget(id)
+		return nil, ErrQueueFull
+	}
+	m.jobs[id] = j
+	return proto.Clone(j.info).(*pb.Job), nil
+}
+
+// Get returns the state of the job id.
+func (m *Manager) Get(id string) (*pb.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(j.info).(*pb.Job), nil
+}
+
+// Watch calls send with the state of the job id, then again every time it
+// changes until the job ends, send fails or ctx is done. States changing
+// faster than send returns are skipped, the last one is always sent.
+func (m *Manager) Watch(ctx context.Context, id string, send func(*pb.Job) error) error {
+	for {
+		m.mu.Lock()
+		j, ok := m.jobs[id]
+		if !ok {
+			m.mu.Unlock()
+			return ErrNotFound
+		}
+		info, changed := proto.Clone(j.info).(*pb.Job), j.changed
+		m.mu.Unlock()
+
+		if err := send(info); err != nil {
+			return err
+		}
+		if ended(info.State) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Cancel aborts the job id and returns its state. Cancelling a job that has
+// ended has no effect.
+func (m *Manager) Cancel(id string) (*pb.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !ended(j.info.State) {
+		if j.cancel != nil {
+			j.cancel()
+		}
+		m.end(j, pb.Job_CANCELLED)
+	}
+	return proto.Clone(j.info).(*pb.Job), nil
+}
+
+// Result returns the transformed image of the job id once it is done.
+func (m *Manager) Result(id string) (*pb.ImageResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if j.info.State != pb.Job_DONE {
+		return nil, ErrNotDone
+	}
+	return m.store.Result(id)
+}
+
+func (m *Manager) work() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j := <-m.queue:
+			m.runJob(j)
+		}
+	}
+}
+
+func (m *Manager) runJob(j *job) {
+	m.mu.Lock()
+	if j.info.State != pb.Job_QUEUED {
+		// cancelled while queued
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	j.cancel = cancel
+	j.info.State = pb.Job_RUNNING
+	m.save(j)
+	m.notify(j)
+	id := j.info.Id
+	m.mu.Unlock()
+
+	in, err := m.store.Input(id)
+	var res *pb.ImageResponse
+	if err == nil {
+		res, err = m.run(ctx, in, func(p float64) { m.progress(j, p) })
+	}
+	if err == nil {
+		err = m.store.SaveResult(id, res)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case ended(j.info.State):
+		// cancelled while running
+	case err == nil:
+		j.info.Progress = 1
+		j.info.Format, j.info.Width, j.info.Height = res.Format, res.Width, res.Height
+		m.end(j, pb.Job_DONE)
+	case m.ctx.Err() != nil:
+		// The Manager is closing, the job runs again with the next one.
+		// Its state is saved as it was when the job started.
+		j.info.State = pb.Job_QUEUED
+	default:
+		j.info.Error = status.Convert(err).Message()
+		m.end(j, pb.Job_FAILED)
+	}
+}
+
+// progress records the progress of a running job. Steps smaller than a
+// percent are not reported to watchers.
+func (m *Manager) progress(j *job, p float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if j.info.State != pb.Job_RUNNING || p < j.info.Progress+0.01 && p < 1 {
+		return
+	}
+	j.info.Progress = p
+	m.notify(j)
+}
+
+// end moves j to a final state, dropping its input.
+func (m *Manager) end(j *job, state pb.Job_State) {
+	j.info.State = state
+	j.info.Ended = time.Now().Unix()
+	j.cancel = nil
+	m.save(j)
+	if err := m.store.DeleteInput(j.info.Id); err != nil {
+		log.Printf("job %s: cannot delete input: %v", j.info.Id, err)
+	}
+	m.notify(j)
+}
+
+// save stores the state of j. A state that cannot be stored is kept in
+// memory, it is only lost if the server restarts.
+func (m *Manager) save(j *job) {
+	if err := m.store.Save(j.info); err != nil {
+		log.Printf("job %s: cannot save state: %v", j.info.Id, err)
+	}
+}
+
+// forget drops what the store holds about the job id.
+func (m *Manager) forget(id string) {
+	if err := m.store.Delete(id); err != nil {
+		log.Printf("job %s: cannot delete: %v", id, err)
+	}
+}
+
+// notify wakes up the watchers of j.
+func (m *Manager) notify(j *job) {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// expire forgets the jobs that ended more than retention ago.
+func (m *Manager) expire() {
+	defer m.wg.Done()
+	tick := time.NewTicker(m.retention/10 + time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-tick.C:
+			m.mu.Lock()
+			for id, j := range m.jobs {
+				if ended(j.info.State) && now.Sub(time.Unix(j.info.Ended, 0)) > m.retention {
+					delete(m.jobs, id)
+					m.forget(id)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+func ended(s pb.Job_State) bool {
+	return s == pb.Job_DONE || s == pb.Job_FAILED || s == pb.Job_CANCELLED
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Status converts an error of this package to a gRPC status error.
+func Status(err error) error {
+	switch err {
+	case ErrNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case ErrQueueFull:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case ErrNotDone:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case ErrClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,115 @@
+package job
+
+import (
+	"sync"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Store keeps the state, input and result of jobs. The jobs of a Store that
+// outlives the server are resumed by the next Manager using it.
+type Store interface {
+	// Jobs returns the state of every job.
+	Jobs() ([]*pb.Job, error)
+	// Save stores the state of a job, replacing the previous one.
+	Save(info *pb.Job) error
+	SaveInput(id string, in *pb.ImageChunk) error
+	Input(id string) (*pb.ImageChunk, error)
+	SaveResult(id string, res *pb.ImageResponse) error
+	Result(id string) (*pb.ImageResponse, error)
+	// DeleteInput drops the input of a job that ended.
+	DeleteInput(id string) error
+	// Delete drops everything stored about a job.
+	Delete(id string) error
+	Close() error
+}
+
+// memoryStore is a Store lost when the server stops.
+type memoryStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*pb.Job
+	inputs  map[string]*pb.ImageChunk
+	results map[string]*pb.ImageResponse
+}
+
+// NewMemoryStore returns a Store keeping jobs in memory.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		jobs:    make(map[string]*pb.Job),
+		inputs:  make(map[string]*pb.ImageChunk),
+		results: make(map[string]*pb.ImageResponse),
+	}
+}
+
+func (s *memoryStore) Jobs() ([]*pb.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*pb.Job, 0, len(s.jobs))
+	for _, info := range s.jobs {
+		res = append(res, proto.Clone(info).(*pb.Job))
+	}
+	return res, nil
+}
+
+func (s *memoryStore) Save(info *pb.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[info.Id] = proto.Clone(info).(*pb.Job)
+	return nil
+}
+
+func (s *memoryStore) SaveInput(id string, in *pb.ImageChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs[id] = in
+	return nil
+}
+
+func (s *memoryStore) Input(id string) (*pb.ImageChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.inputs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return in, nil
+}
+
+func (s *memoryStore) SaveResult(id string, res *pb.ImageResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[id] = res
+	return nil
+}
+
+func (s *memoryStore) Result(id string) (*pb.ImageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return res, nil
+}
+
+func (s *memoryStore) DeleteInput(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inputs, id)
+	return nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	delete(s.inputs, id)
+	delete(s.results, id)
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,330 @@
+// Package metrics records counters, gauges and histograms, and exposes them
+// over HTTP in the text format of Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of a histogram of
+// durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds the metrics exposed together. It is safe for concurrent
+// use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// family is a metric and its series, one per combination of the values of
+// its labels.
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64 // of histograms
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for given values of its labels.
+type series struct {
+	values []string
+	// bits of the value of counters and gauges, updated atomically
+	bits uint64
+
+	// counts of the observations of histograms in every bucket, the
+	// last one counting all of them
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " is registered twice")
+	}
+	r.names[name] = true
+	f := &family{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// with returns the series of the values of the labels of f.
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.series[key]
+	if s == nil {
+		s = &series{values: append([]string(nil), values...)}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (s *series) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&s.bits)
+		if atomic.CompareAndSwapUint64(&s.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (s *series) value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+// Counter is a metric that only goes up, such as the number of calls.
+type Counter struct {
+	f *family
+}
+
+// Counter registers the counter name, with the labels given.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", nil, labels)}
+}
+
+// With returns the value of c for the values of its labels, in order. It
+// is cheaper to keep than to look up on every update.
+func (c *Counter) With(values ...string) *CounterValue {
+	return &CounterValue{c.f.with(values)}
+}
+
+// CounterValue is the value of a counter for given values of its labels.
+type CounterValue struct {
+	s *series
+}
+
+// Add adds v to the counter. v must not be negative.
+func (c *CounterValue) Add(v float64) {
+	c.s.add(v)
+}
+
+// Inc adds 1 to the counter.
+func (c *CounterValue) Inc() {
+	c.s.add(1)
+}
+
+// Gauge is a metric going up and down, such as the number of calls in
+// flight.
+type Gauge struct {
+	f *family
+}
+
+// Gauge registers the gauge name, with the labels given.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", nil, labels)}
+}
+
+// With returns the value of g for the values of its labels, in order.
+func (g *Gauge) With(values ...string) *GaugeValue {
+	return &GaugeValue{g.f.with(values)}
+}
+
+// GaugeValue is the value of a gauge for given values of its labels.
+type GaugeValue struct {
+	s *series
+}
+
+// Add adds v to the gauge, which goes down when v is negative.
+func (g *GaugeValue) Add(v float64) {
+	g.s.add(v)
+}
+
+// Set sets the gauge to v.
+func (g *GaugeValue) Set(v float64) {
+	atomic.StoreUint64(&g.s.bits, math.Float64bits(v))
+}
+
+// Histogram counts observations, such as latencies, in buckets.
+type Histogram struct {
+	f *family
+}
+
+// Histogram registers the histogram name, with the upper bounds of its
+// buckets in increasing order, and the labels given.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: the buckets of " + name + " are not in increasing order")
+	}
+	return &Histogram{r.register(name, help, "histogram", append([]float64(nil), buckets...), labels)}
+}
+
+// With returns the value of h for the values of its labels, in order.
+func (h *Histogram) With(values ...string) *HistogramValue {
+	return &HistogramValue{h.f.with(values), h.f.buckets}
+}
+
+// HistogramValue is the value of a histogram for given values of its
+// labels.
+type HistogramValue struct {
+	s       *series
+	buckets []float64
+}
+
+// Observe records v.
+func (h *HistogramValue) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.s.mu.Lock()
+	h.s.counts[i]++
+	h.s.sum += v
+	h.s.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics of r in the text format of Prometheus.
+// Metrics without any value are left out.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Serve serves the metrics of r at /metrics on addr, in the background once
+// it listens. The server returned has the address listened to, and stops
+// with its Shutdown or Close methods. It failing later is logged.
+func Serve(addr string, r *Registry) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	srv := &http.Server{
+		Addr:              lis.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	go func() {
+		if err := srv.Serve(lis); err != http.ErrServerClosed {
+			log.Printf("metrics: %v", err)
+		}
+	}()
+	return srv, nil
+}
+
+// Write writes the metrics of r to w in the text format of Prometheus.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]*series, len(keys))
+	for i, k := range keys {
+		series[i] = f.series[k]
+	}
+	f.mu.Unlock()
+	if len(series) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	for _, s := range series {
+		if f.buckets == nil {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelSet(s.values, ""), formatFloat(s.value()))
+			continue
+		}
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum := s.sum
+		s.mu.Unlock()
+		var total uint64
+		for i, n := range counts {
+			total += n
+			le := "+Inf"
+			if i < len(f.buckets) {
+				le = formatFloat(f.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelSet(s.values, le), total)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelSet(s.values, ""), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelSet(s.values, ""), total)
+	}
+}
+
+// labelSet returns the labels of f set to values, followed by the upper
+// bound le of a bucket unless it is empty.
+func (f *family) labelSet(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", f.labels[i], escapeValue(v))
+	}
+	if le != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "le=\"%s\"", le)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		// counts are written whole rather than in exponent notation
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
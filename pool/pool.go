@@ -0,0 +1,175 @@
+// Package pool spreads the work of a stream over several goroutines while
+// a single one sends the results, as gRPC streams allow only one sender at
+// a time.
+package pool
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrStopped is returned by Submit once the pool was stopped.
+var ErrStopped = errors.New("pool stopped")
+
+// Task computes a result and returns the function sending it. The send
+// functions of a pool are called one at a time, from a single goroutine.
+type Task func() (send func() error)
+
+type task struct {
+	seq int
+	run Task
+}
+
+type result struct {
+	seq  int
+	send func() error
+}
+
+// Pool runs tasks on a fixed number of workers. Results are sent as soon as
+// they are ready, or in the order their tasks were submitted when the pool
+// is ordered.
+type Pool struct {
+	ordered bool
+	tasks   chan task
+	results chan result
+	// slots bounds the tasks submitted and not sent yet, so that a slow
+	// receiver or a slow task holds back Submit.
+	slots   chan struct{}
+	next    int // sequence of the next task submitted
+	workers sync.WaitGroup
+
+	// failed is closed once sending failed or the pool was stopped, err
+	// holds why.
+	failed  chan struct{}
+	fail    sync.Once
+	err     error
+	emitted chan struct{} // closed once every result was handled
+	close   sync.Once
+}
+
+// New starts a pool of workers goroutines, at least one.
+func New(workers int, ordered bool) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	// enough in flight to keep every worker busy while results are sent
+	size := 4 * workers
+	p := &Pool{
+		ordered: ordered,
+		tasks:   make(chan task, size),
+		results: make(chan result, size),
+		slots:   make(chan struct{}, size),
+		failed:  make(chan struct{}),
+		emitted: make(chan struct{}),
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	go p.emit()
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for t := range p.tasks {
+		select {
+		case <-p.failed:
+			// nothing is sent anymore, skip the work
+			p.results <- result{seq: t.seq}
+		default:
+			p.results <- result{seq: t.seq, send: t.run()}
+		}
+	}
+}
+
+// emit sends the results of the workers until they are all handled.
+func (p *Pool) emit() {
+	defer close(p.emitted)
+	pending := make(map[int]func() error)
+	next := 0
+	for r := range p.results {
+		if !p.ordered {
+			p.send(r.send)
+			continue
+		}
+		pending[r.seq] = r.send
+		for {
+			send, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			p.send(send)
+		}
+	}
+}
+
+// send calls f unless sending already failed, and frees its slot.
+func (p *Pool) send(f func() error) {
+	defer func() { <-p.slots }()
+	select {
+	case <-p.failed:
+		return
+	default:
+	}
+	if f == nil {
+		return
+	}
+	if err := f(); err != nil {
+		p.setErr(err)
+	}
+}
+
+func (p *Pool) setErr(err error) {
+	p.fail.Do(func() {
+		p.err = err
+		close(p.failed)
+	})
+}
+
+// Submit queues t, waiting while too many results are not sent yet. It
+// returns the error of the first send that failed, after which tasks are
+// no longer run. Submit must not be called concurrently, nor after Wait.
+func (p *Pool) Submit(t Task) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.failed:
+		return p.err
+	}
+	p.tasks <- task{seq: p.next, run: t}
+	p.next++
+	return nil
+}
+
+// Wait returns once the result of every task submitted was sent, with the
+// error of the first send that failed. It stops the workers.
+func (p *Pool) Wait() error {
+	p.close.Do(func() {
+		close(p.tasks)
+		p.workers.Wait()
+		close(p.results)
+	})
+	<-p.emitted
+	select {
+	case <-p.failed:
+		if p.err == ErrStopped {
+			return nil
+		}
+		return p.err
+	default:
+		return nil
+	}
+}
+
+// Stop drops the results not sent yet and waits for the workers to return.
+// Nothing is sent once it returns. It does nothing to a nil pool or to a
+// pool whose results were all sent.
+func (p *Pool) Stop() {
+	if p == nil {
+		return
+	}
+	p.setErr(ErrStopped)
+	p.Wait()
+}
@@ -0,0 +1,249 @@
+package pool_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/pool"
+)
+
+// jitter sleeps up to a millisecond, so that tasks finish out of order.
+func jitter(r *rand.Rand) time.Duration {
+	return time.Duration(r.Intn(1000)) * time.Microsecond
+}
+
+func TestOrdered(t *testing.T) {
+	p := pool.New(8, true)
+	r := rand.New(rand.NewSource(1))
+	var got []int
+	for i := 0; i < 200; i++ {
+		i, d := i, jitter(r)
+		err := p.Submit(func() func() error {
+			time.Sleep(d)
+			return func() error {
+				got = append(got, i)
+				return nil
+			}
+		})
+		if err != nil {
+			t.Fatalf("Submit(%d) = %v", i, err)
+		}
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	if len(got) != 200 {
+		t.Fatalf("got %d results, want 200", len(got))
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("result %d is the one of task %d", i, v)
+		}
+	}
+}
+
+func TestUnordered(t *testing.T) {
+	p := pool.New(8, false)
+	r := rand.New(rand.NewSource(1))
+	seen := make([]int, 200)
+	for i := range seen {
+		i, d := i, jitter(r)
+		err := p.Submit(func() func() error {
+			time.Sleep(d)
+			return func() error {
+				seen[i]++
+				return nil
+			}
+		})
+		if err != nil {
+			t.Fatalf("Submit(%d) = %v", i, err)
+		}
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Errorf("result of task %d sent %d times", i, n)
+		}
+	}
+}
+
+func TestSendError(t *testing.T) {
+	for _, ordered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ordered=%v", ordered), func(t *testing.T) {
+			errSend := errors.New("send failed")
+			p := pool.New(4, ordered)
+			var ran, sent int64
+			submit := func(i int) error {
+				return p.Submit(func() func() error {
+					atomic.AddInt64(&ran, 1)
+					return func() error {
+						atomic.AddInt64(&sent, 1)
+						if i == 10 {
+							return errSend
+						}
+						return nil
+					}
+				})
+			}
+
+			var err error
+			for i := 0; i < 1000 && err == nil; i++ {
+				err = submit(i)
+			}
+			if err != errSend {
+				t.Fatalf("Submit returned %v once sending failed, want %v", err, errSend)
+			}
+			if err := p.Wait(); err != errSend {
+				t.Fatalf("Wait() = %v, want %v", err, errSend)
+			}
+			// a slot is only freed by a send, so a few tasks at most get
+			// through after the failure
+			if n := atomic.LoadInt64(&ran); n > 100 {
+				t.Errorf("%d tasks ran after the send of task 10 failed", n)
+			}
+			if ordered {
+				if n := atomic.LoadInt64(&sent); n != 11 {
+					t.Errorf("%d results sent, want the 11 up to the one failing", n)
+				}
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	p := pool.New(4, false)
+	started, release := make(chan struct{}, 8), make(chan struct{})
+	var sent int64
+	for i := 0; i < 8; i++ {
+		err := p.Submit(func() func() error {
+			started <- struct{}{}
+			<-release
+			return func() error {
+				atomic.AddInt64(&sent, 1)
+				return nil
+			}
+		})
+		if err != nil {
+			t.Fatalf("Submit(%d) = %v", i, err)
+		}
+	}
+
+	// every worker is running a task, the other tasks are left out
+	for i := 0; i < 4; i++ {
+		<-started
+	}
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while tasks were running")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+
+	if n := atomic.LoadInt64(&sent); n != 0 {
+		t.Errorf("%d results sent after Stop", n)
+	}
+	if n := len(started); n != 0 {
+		t.Errorf("%d more tasks ran after Stop", n)
+	}
+	if err := p.Wait(); err != nil {
+		t.Errorf("Wait after Stop = %v, want nil", err)
+	}
+	p.Stop()
+
+	var nilPool *pool.Pool
+	nilPool.Stop()
+}
+
+// BenchmarkPool measures the throughput of a Transform stream running a
+// blur over a synthetic image, pixels fed row by row and every row computed
+// and handed over by a pool of workers, for worker counts up to the number
+// of CPUs.
+func BenchmarkPool(b *testing.B) {
+	const width, height = 1024, 768
+	src := filter.NewFrame(width, height)
+	r := rand.New(rand.NewSource(1))
+	for i := range src.Pix {
+		src.Pix[i] = filter.Color{R: float32(r.Intn(256)), G: float32(r.Intn(256)), B: float32(r.Intn(256)), A: 255}
+	}
+	blur, err := filter.New(filter.Spec{Name: "blur", Params: filter.Params{"radius": 4}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var counts []int
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		counts = append(counts, n)
+	}
+	counts = append(counts, runtime.NumCPU())
+	for _, workers := range counts {
+		workers := workers
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(width * height * 4)
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if err := transform(src, blur, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)*width*height/time.Since(start).Seconds()/1e6, "Mpixel/s")
+		})
+	}
+}
+
+// transform runs op over src through a window, as a Transform stream does,
+// computing the rows on a pool of workers goroutines.
+func transform(src *filter.Frame, op filter.Op, workers int) error {
+	window, err := filter.NewWindow(src.Width, src.Height, op)
+	if err != nil {
+		return err
+	}
+	dst := filter.NewFrame(window.Width(), window.Height())
+	p := pool.New(workers, false)
+	defer p.Stop()
+	submit := func(rows []int) error {
+		for _, y := range rows {
+			y := y
+			err := p.Submit(func() func() error {
+				row := make([]filter.Color, window.Width())
+				window.Row(y, row)
+				return func() error {
+					copy(dst.Row(y), row)
+					return nil
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for y := 0; y < src.Height; y++ {
+		for x, c := range src.Row(y) {
+			ready, err := window.Set(x, y, c)
+			if err != nil {
+				return err
+			}
+			if err := submit(ready); err != nil {
+				return err
+			}
+		}
+	}
+	if err := submit(window.Flush()); err != nil {
+		return err
+	}
+	return p.Wait()
+}
@@ -0,0 +1,223 @@
+// Package progress reports how far a long transfer got: as a bar redrawn in
+// place for people, or as lines of JSON for scripts. Updates are rendered
+// at a bounded rate however often they come.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode selects how progress is rendered.
+type Mode int
+
+const (
+	// Bar redraws a bar with the throughput and remaining time on a
+	// terminal, and writes it as a line at every update otherwise.
+	Bar Mode = iota
+	// JSON writes every update as a line holding a JSON object.
+	JSON
+	// Quiet renders nothing.
+	Quiet
+)
+
+var modeNames = []string{"bar", "json", "quiet"}
+
+func (m Mode) String() string {
+	if int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ParseMode returns the mode named s.
+func ParseMode(s string) (Mode, error) {
+	for i, name := range modeNames {
+		if s == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown progress mode %q, expected one of %s", s, strings.Join(modeNames, ", "))
+}
+
+// Rates at which updates are rendered.
+const (
+	terminalInterval = 100 * time.Millisecond
+	lineInterval     = time.Second
+	barWidth         = 30
+)
+
+// Update is the state of a transfer as rendered in JSON mode.
+type Update struct {
+	Label string `json:"label"`
+	Done  int64  `json:"done"`
+	// Total is 0 when not known.
+	Total int64 `json:"total"`
+	// Rate is the number of units done per second since the start.
+	Rate    float64 `json:"rate"`
+	Elapsed float64 `json:"elapsed_seconds"`
+	// ETA is the estimated number of seconds left, -1 when not known.
+	ETA      float64 `json:"eta_seconds"`
+	Finished bool    `json:"finished,omitempty"`
+	// Note is what the other end reported, if anything.
+	Note string `json:"note,omitempty"`
+}
+
+// Reporter renders the progress of one transfer. It is safe for concurrent
+// use.
+type Reporter struct {
+	w        io.Writer
+	mode     Mode
+	label    string
+	unit     string
+	terminal bool
+	interval time.Duration
+	start    time.Time
+
+	mu       sync.Mutex
+	done     int64
+	total    int64
+	note     string
+	rendered time.Time
+	finished bool
+}
+
+// New returns a reporter writing to w the progress of label, counted in
+// unit, out of total when it is not 0.
+func New(w io.Writer, mode Mode, label, unit string, total int64) *Reporter {
+	r := &Reporter{w: w, mode: mode, label: label, unit: unit, total: total, start: time.Now()}
+	if f, ok := w.(*os.File); ok && mode == Bar {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			r.terminal = true
+		}
+	}
+	r.interval = lineInterval
+	if r.terminal {
+		r.interval = terminalInterval
+	}
+	return r
+}
+
+// SetTotal changes the total once it is known.
+func (r *Reporter) SetTotal(total int64) {
+	r.mu.Lock()
+	r.total = total
+	r.mu.Unlock()
+}
+
+// SetNote sets a note rendered along with the progress, such as what the
+// other end of the transfer reported.
+func (r *Reporter) SetNote(note string) {
+	r.mu.Lock()
+	r.note = note
+	r.mu.Unlock()
+}
+
+// Set records that done units are done, rendering the progress when it was
+// not rendered for a while.
+func (r *Reporter) Set(done int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = done
+	if r.finished || time.Since(r.rendered) < r.interval {
+		return
+	}
+	r.render()
+}
+
+// Finish renders the final progress. Later updates are ignored.
+func (r *Reporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	r.finished = true
+	r.render()
+	if r.terminal {
+		fmt.Fprintln(r.w)
+	}
+}
+
+func (r *Reporter) update() Update {
+	elapsed := time.Since(r.start).Seconds()
+	u := Update{Label: r.label, Done: r.done, Total: r.total, Elapsed: elapsed, ETA: -1, Finished: r.finished, Note: r.note}
+	if elapsed > 0 {
+		u.Rate = float64(r.done) / elapsed
+	}
+	if r.finished {
+		u.ETA = 0
+	} else if u.Rate > 0 && r.total > 0 {
+		u.ETA = float64(r.total-r.done) / u.Rate
+	}
+	return u
+}
+
+func (r *Reporter) render() {
+	r.rendered = time.Now()
+	u := r.update()
+	switch r.mode {
+	case JSON:
+		b, _ := json.Marshal(u)
+		fmt.Fprintf(r.w, "%s\n", b)
+	case Bar:
+		line := r.bar(u)
+		if r.terminal {
+			// \033[K clears what a longer line left
+			fmt.Fprintf(r.w, "\r%s\033[K", line)
+		} else {
+			fmt.Fprintln(r.w, line)
+		}
+	}
+}
+
+// bar formats u as "label [====>    ]  42% 1.2M pixels/s ETA 3s".
+func (r *Reporter) bar(u Update) string {
+	var b strings.Builder
+	b.WriteString(u.Label)
+	if u.Total > 0 {
+		fraction := float64(u.Done) / float64(u.Total)
+		if fraction > 1 {
+			fraction = 1
+		}
+		filled := int(fraction * barWidth)
+		b.WriteString(" [")
+		b.WriteString(strings.Repeat("=", filled))
+		if filled < barWidth {
+			b.WriteString(">")
+			b.WriteString(strings.Repeat(" ", barWidth-filled-1))
+		}
+		fmt.Fprintf(&b, "] %3.0f%%", fraction*100)
+	} else {
+		fmt.Fprintf(&b, " %s %s", human(float64(u.Done)), r.unit)
+	}
+	fmt.Fprintf(&b, " %s %s/s", human(u.Rate), r.unit)
+	switch {
+	case u.Finished:
+		fmt.Fprintf(&b, " in %v", time.Duration(u.Elapsed*float64(time.Second)).Round(time.Millisecond))
+	case u.ETA >= 0:
+		fmt.Fprintf(&b, " ETA %v", time.Duration(u.ETA*float64(time.Second)).Round(time.Second))
+	}
+	if u.Note != "" && !u.Finished {
+		fmt.Fprintf(&b, " (%s)", u.Note)
+	}
+	return b.String()
+}
+
+// human formats v with a k, M or G suffix.
+func human(v float64) string {
+	switch {
+	case v >= 1e9:
+		return fmt.Sprintf("%.1fG", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
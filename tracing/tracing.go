@@ -0,0 +1,129 @@
+// Package tracing sets up OpenTelemetry tracing: spans are exported with the
+// stdout exporter, as lines of JSON, or to a collector over OTLP. Calls pass
+// their traces on in the traceparent and tracestate metadata of the W3C
+// trace context, see package interceptor.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Scope is the instrumentation scope of the spans started by this module.
+const Scope = "nichowil/grpc-tutorial"
+
+// closeTimeout bounds how long Close waits for the spans to be exported.
+const closeTimeout = 10 * time.Second
+
+// Provider starts the spans of a service and exports them once they end.
+type Provider struct {
+	*sdktrace.TracerProvider
+	file *os.File
+}
+
+// NewProvider returns the provider of the spans of service, exported as spec
+// tells:
+//
+//	stdout, stderr    JSON lines on the standard output or error
+//	file:PATH         JSON lines appended to the file at PATH
+//	otlp              OTLP over HTTP to a collector on localhost:4318, or as
+//	                  the OTEL_EXPORTER_OTLP_* environment variables tell
+//	http(s)://HOST    OTLP over HTTP to the collector at the URL
+//
+// Lines are written as spans end; spans are sent to a collector in batches
+// in the background.
+func NewProvider(service, spec string) (*Provider, error) {
+	p := &Provider{}
+	var opt sdktrace.TracerProviderOption
+	switch {
+	case spec == "stdout" || spec == "stderr" || strings.HasPrefix(spec, "file:"):
+		w := os.Stdout
+		if spec == "stderr" {
+			w = os.Stderr
+		}
+		if strings.HasPrefix(spec, "file:") {
+			f, err := os.OpenFile(strings.TrimPrefix(spec, "file:"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			w, p.file = f, f
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			p.closeFile()
+			return nil, err
+		}
+		opt = sdktrace.WithSyncer(e)
+	case spec == "otlp" || strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		var opts []otlptracehttp.Option
+		if spec != "otlp" {
+			url := strings.TrimSuffix(spec, "/")
+			if !strings.HasSuffix(url, "/v1/traces") {
+				url += "/v1/traces"
+			}
+			opts = append(opts, otlptracehttp.WithEndpointURL(url))
+		}
+		e, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		opt = sdktrace.WithBatcher(e)
+	default:
+		return nil, fmt.Errorf("unknown span exporter %q, expected stdout, stderr, file:PATH, otlp or the URL of an OTLP collector", spec)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		p.closeFile()
+		return nil, err
+	}
+	p.TracerProvider = sdktrace.NewTracerProvider(opt, sdktrace.WithResource(res))
+	return p, nil
+}
+
+// Close exports the spans that ended and were not exported yet, then closes
+// the exporter. Spans ending later are dropped.
+func (p *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	err := p.Shutdown(ctx)
+	if cerr := p.closeFile(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (p *Provider) closeFile() error {
+	if p.file == nil {
+		return nil
+	}
+	f := p.file
+	p.file = nil
+	return f.Close()
+}
+
+// Start starts the span name of an operation within the span of ctx, with
+// the provider of that span. The span does nothing when ctx has no span, so
+// that code may be traced without knowing whether it is.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(Scope).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, the operation that failed with err unless it is nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,248 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"time"
+
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults of a Resumable transfer.
+const (
+	DefaultRetries    = 5
+	DefaultBackoff    = 500 * time.Millisecond
+	DefaultTileHeight = 16
+)
+
+// Resumable runs a Transfer call sending an image row by row. When the
+// stream fails with codes.Unavailable, as it does when the connection
+// drops, the transfer is resumed on a new stream from the last rows the
+// server acknowledged, and the rows of the result received are not sent
+// again.
+type Resumable struct {
+	// Width and Height are the size of the image, Pipeline the filters
+	// applied to it.
+	Width, Height int
+	Pipeline      *pb.Pipeline
+	// Rows returns rows lo to hi of the image, excluded, as packed 8-bit
+	// RGBA.
+	Rows func(lo, hi int) []byte
+	// TileHeight is the number of rows sent per message, DefaultTileHeight
+	// when 0.
+	TileHeight int
+	// Size is called with the size of the result before its first rows
+	// are received, when set.
+	Size func(width, height int) error
+	// Recv is called with the rows of the result, in order. A resumed
+	// transfer does not receive them again.
+	Recv func(rows *pb.Tile) error
+	// Retries bounds the streams started after the first one failed,
+	// DefaultRetries when 0. Streams that acknowledged rows reset the
+	// count. Backoff is the wait before the first retry, doubled at every
+	// one, DefaultBackoff when 0.
+	Retries int
+	Backoff time.Duration
+	// Retry is called with the error of a stream before the transfer is
+	// resumed, when set.
+	Retry func(err error, wait time.Duration)
+	// Progress is called with the rows acknowledged and received so far,
+	// when set.
+	Progress func(acked, received int)
+
+	// ID identifies the transfer once the server started it.
+	ID              string
+	acked, received int
+	// size of the result, once known
+	outWidth, outHeight int
+	sized               bool
+}
+
+// Run runs the transfer, resuming it when its stream is lost. It returns an
+// *Error when the transfer failed.
+func (r *Resumable) Run(ctx context.Context, client pb.TransformClient, opts ...grpc.CallOption) error {
+	retries, backoff := r.Retries, r.Backoff
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	failed, wait := 0, backoff
+	for {
+		acked := r.acked
+		err := r.stream(ctx, client, opts...)
+		if err == nil {
+			return nil
+		}
+		if r.acked > acked {
+			failed, wait = 0, backoff
+		}
+		if status.Code(err) != codes.Unavailable || failed >= retries {
+			return r.error(err)
+		}
+		failed++
+		if r.Retry != nil {
+			r.Retry(err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return r.error(status.FromContextError(ctx.Err()).Err())
+		}
+		wait *= 2
+	}
+}
+
+func (r *Resumable) error(err error) error {
+	return &Error{
+		Sent:      r.acked * r.Width,
+		Confirmed: r.received * r.outWidth,
+		Total:     r.Width * r.Height,
+		Err:       err,
+	}
+}
+
+// stream starts or resumes the transfer on a new stream and runs it until
+// the stream ends.
+func (r *Resumable) stream(ctx context.Context, client pb.TransformClient, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Transfer(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	start := &pb.TransferStart{Id: r.ID, Received: int32(r.received)}
+	if r.ID == "" {
+		start.Width, start.Height, start.Pipeline = int32(r.Width), int32(r.Height), r.Pipeline
+	}
+	if err := stream.Send(&pb.TransferRequest{Message: &pb.TransferRequest_Start{Start: start}}); err != nil {
+		return r.recvError(stream, err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	ack := res.GetAck()
+	if ack == nil {
+		return status.Error(codes.Internal, "the transfer was not acknowledged")
+	}
+	if err := r.ack(ack); err != nil {
+		return err
+	}
+
+	// rows are sent from the last ones acknowledged while the result is
+	// received
+	sendc := make(chan error, 1)
+	from := r.acked
+	go func() {
+		sendc <- r.send(ctx, stream, from)
+	}()
+
+	for r.received < r.outHeight {
+		res, err := stream.Recv()
+		if err != nil {
+			cancel()
+			<-sendc
+			if err == io.EOF {
+				return status.Errorf(codes.Internal, "the stream ended after %d of %d rows of the result", r.received, r.outHeight)
+			}
+			return err
+		}
+		switch m := res.Message.(type) {
+		case *pb.TransferResponse_Ack:
+			err = r.ack(m.Ack)
+		case *pb.TransferResponse_Rows:
+			err = r.rows(m.Rows)
+		}
+		if err != nil {
+			cancel()
+			<-sendc
+			return err
+		}
+	}
+	cancel()
+	<-sendc
+	return nil
+}
+
+// send sends the rows of the image from row y.
+func (r *Resumable) send(ctx context.Context, stream pb.Transform_TransferClient, y int) error {
+	height := r.TileHeight
+	if height <= 0 {
+		height = DefaultTileHeight
+	}
+	for ; y < r.Height; y += height {
+		hi := y + height
+		if hi > r.Height {
+			hi = r.Height
+		}
+		tile := &pb.Tile{
+			Origin: &pb.Point{Y: int32(y)},
+			Width:  int32(r.Width),
+			Height: int32(hi - y),
+			Rgba:   r.Rows(y, hi),
+		}
+		if err := stream.Send(&pb.TransferRequest{Message: &pb.TransferRequest_Rows{Rows: tile}}); err != nil {
+			// the receiving side learns why the stream failed
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return stream.CloseSend()
+}
+
+func (r *Resumable) ack(ack *pb.TransferAck) error {
+	if r.ID == "" {
+		r.ID = ack.Id
+	}
+	if !r.sized {
+		r.outWidth, r.outHeight = int(ack.Width), int(ack.Height)
+		r.sized = true
+		if r.Size != nil {
+			if err := r.Size(int(ack.Width), int(ack.Height)); err != nil {
+				return err
+			}
+		}
+	}
+	r.acked = int(ack.Rows)
+	r.progress()
+	return nil
+}
+
+func (r *Resumable) rows(tile *pb.Tile) error {
+	if int(tile.Origin.GetY()) != r.received {
+		return status.Errorf(codes.Internal, "expected rows of the result from %d, got %d", r.received, tile.Origin.GetY())
+	}
+	if err := r.Recv(tile); err != nil {
+		return err
+	}
+	r.received += int(tile.Height)
+	r.progress()
+	return nil
+}
+
+func (r *Resumable) progress() {
+	if r.Progress != nil {
+		r.Progress(r.acked, r.received)
+	}
+}
+
+// recvError returns the error that ended stream when err, returned by Send,
+// only reports that it ended.
+func (r *Resumable) recvError(stream pb.Transform_TransferClient, err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if _, err := stream.Recv(); err != nil && err != io.EOF {
+		return err
+	}
+	return status.Error(codes.Internal, "the stream ended before the transfer started")
+}
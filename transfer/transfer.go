@@ -0,0 +1,325 @@
+// Package transfer runs the client side of the Transform streams. It sends
+// messages and receives the results concurrently, stops both at the first
+// error, whichever side it comes from, and reports how much of the image
+// the server confirmed before it failed.
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"nichowil/grpc-tutorial/filter"
+	"nichowil/grpc-tutorial/integrity"
+	pb "nichowil/grpc-tutorial/transform"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultWindow is the number of messages queued ahead of a stream when a
+// Transfer does not set one.
+const DefaultWindow = 16
+
+// Error reports a transfer that failed. It carries the gRPC status of Err.
+type Error struct {
+	// Sent and Confirmed count the pixels sent to the server and received
+	// back from it when the transfer stopped, out of Total.
+	Sent, Confirmed, Total int
+	Err                    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("transfer failed after %d of %d pixels were sent and %d confirmed: %v", e.Sent, e.Total, e.Confirmed, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns the status of the error that stopped the transfer, so
+// that status.FromError sees through Error.
+func (e *Error) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
+
+// Transfer describes one call of a Transform stream.
+type Transfer struct {
+	// Total is the number of pixels to send, as reported by Error.
+	Total int
+	// Window bounds the messages prepared and waiting to be sent,
+	// DefaultWindow when 0. The flow control of the stream bounds those
+	// sent and not read by the server yet.
+	Window int
+	// Header is called with the response header before the first result
+	// is received, when set. An error stops the transfer.
+	Header func(metadata.MD) error
+	// Progress is called with the pixels sent and confirmed so far every
+	// time a message is sent or received, when set. Calls do not overlap.
+	Progress func(sent, confirmed int)
+	// Verify ends the stream with a summary of the pixels sent and checks
+	// the summary the server ends its stream with, see pb.Summary. Pixels
+	// lost or altered fail the transfer with codes.DataLoss.
+	Verify bool
+	// ProgressInterval asks the server to report its progress that often,
+	// see pb.Progress, and ServerProgress is called with every report.
+	// Reports are neither counted nor verified.
+	ProgressInterval time.Duration
+	ServerProgress   func(*pb.Progress)
+}
+
+// callContext returns ctx with the metadata asking for the options of t.
+func (t *Transfer) callContext(ctx context.Context) context.Context {
+	if t.ProgressInterval <= 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, filter.MetadataProgressInterval, t.ProgressInterval.String())
+}
+
+func (t *Transfer) serverProgress(p *pb.Progress) {
+	if t.ServerProgress != nil {
+		t.ServerProgress(p)
+	}
+}
+
+// Tiles runs a TransformTiles call sending the tiles returned by next, until
+// it returns nil, and handing every tile received to recv. It returns the
+// trailer of the call, or an *Error.
+func (t *Transfer) Tiles(ctx context.Context, client pb.TransformClient, next func() *pb.Tile, recv func(*pb.Tile) error, opts ...grpc.CallOption) (metadata.MD, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.TransformTiles(t.callContext(ctx), opts...)
+	if err != nil {
+		return nil, &Error{Total: t.Total, Err: err}
+	}
+	var (
+		sent, received integrity.Tally
+		summary        *pb.Summary
+		summarized     bool
+	)
+	return t.run(stream, cancel,
+		func() (interface{}, int) {
+			if tile := next(); tile != nil {
+				if t.Verify {
+					sent.AddTile(tile)
+				}
+				return tile, len(tile.Rgba) / 4
+			}
+			if t.Verify && !summarized {
+				summarized = true
+				return &pb.Tile{Summary: &pb.Summary{Input: sent.Proto()}}, 0
+			}
+			return nil, 0
+		},
+		func(m interface{}) error { return stream.Send(m.(*pb.Tile)) },
+		func() (int, error) {
+			tile, err := stream.Recv()
+			if err != nil {
+				return 0, err
+			}
+			if tile.Summary != nil {
+				summary = tile.Summary
+				return 0, nil
+			}
+			if tile.Progress != nil {
+				t.serverProgress(tile.Progress)
+				return 0, nil
+			}
+			if t.Verify {
+				received.AddTile(tile)
+			}
+			return len(tile.Rgba) / 4, recv(tile)
+		},
+		func() error {
+			if !t.Verify {
+				return nil
+			}
+			return verify(summary, sent, received)
+		},
+	)
+}
+
+// Pixels runs a Transform call sending the pixels returned by next, until it
+// returns nil, and handing every pixel received to recv. It returns the
+// trailer of the call, or an *Error.
+func (t *Transfer) Pixels(ctx context.Context, client pb.TransformClient, next func() *pb.Pixel, recv func(*pb.Pixel) error, opts ...grpc.CallOption) (metadata.MD, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.Transform(t.callContext(ctx), opts...)
+	if err != nil {
+		return nil, &Error{Total: t.Total, Err: err}
+	}
+	var (
+		sent, received integrity.Tally
+		summary        *pb.Summary
+		summarized     bool
+	)
+	return t.run(stream, cancel,
+		func() (interface{}, int) {
+			if pixel := next(); pixel != nil {
+				if t.Verify {
+					sent.AddPixel(pixel)
+				}
+				return pixel, 1
+			}
+			if t.Verify && !summarized {
+				summarized = true
+				return &pb.Pixel{Summary: &pb.Summary{Input: sent.Proto()}}, 0
+			}
+			return nil, 0
+		},
+		func(m interface{}) error { return stream.Send(m.(*pb.Pixel)) },
+		func() (int, error) {
+			pixel, err := stream.Recv()
+			if err != nil {
+				return 0, err
+			}
+			if pixel.Summary != nil {
+				summary = pixel.Summary
+				return 0, nil
+			}
+			if pixel.Progress != nil {
+				t.serverProgress(pixel.Progress)
+				return 0, nil
+			}
+			if t.Verify {
+				received.AddPixel(pixel)
+			}
+			return 1, recv(pixel)
+		},
+		func() error {
+			if !t.Verify {
+				return nil
+			}
+			return verify(summary, sent, received)
+		},
+	)
+}
+
+// verify compares the summary the server ended its stream with to the
+// pixels sent and received.
+func verify(summary *pb.Summary, sent, received integrity.Tally) error {
+	if summary == nil {
+		return status.Error(codes.DataLoss, "the server did not end the stream with a summary of its pixels")
+	}
+	if err := integrity.Compare("pixels sent", sent, integrity.FromProto(summary.Input)); err != nil {
+		return err
+	}
+	return integrity.Compare("pixels received", integrity.FromProto(summary.Output), received)
+}
+
+// message is a message waiting to be sent with the number of pixels it
+// holds.
+type message struct {
+	m      interface{}
+	pixels int
+}
+
+// run drives stream: next returns the messages to send, nil once done, send
+// sends one and recv receives one, returning the pixels it confirmed. The
+// first error cancels the stream. check is called once the stream ended
+// without one.
+func (t *Transfer) run(stream grpc.ClientStream, cancel context.CancelFunc, next func() (interface{}, int), send func(interface{}) error, recv func() (int, error), check func() error) (metadata.MD, error) {
+	var (
+		mu              sync.Mutex
+		sent, confirmed int
+		failure         error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if failure == nil {
+			failure = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+	count := func(s, c int) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent += s
+		confirmed += c
+		if t.Progress != nil {
+			t.Progress(sent, confirmed)
+		}
+	}
+
+	window := t.Window
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	queue := make(chan message, window)
+	stopped := make(chan struct{}) // closed once nothing more is sent
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		defer close(stopped)
+		for msg := range queue {
+			if err := send(msg.m); err != nil {
+				// io.EOF means the server ended the stream, recv learns why
+				if err != io.EOF {
+					fail(err)
+				}
+				return
+			}
+			count(msg.pixels, 0)
+		}
+		if err := stream.CloseSend(); err != nil {
+			fail(err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		header, err := stream.Header()
+		if err != nil {
+			fail(err)
+			return
+		}
+		if t.Header != nil {
+			if err := t.Header(header); err != nil {
+				fail(err)
+				return
+			}
+		}
+		for {
+			n, err := recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+			count(0, n)
+		}
+	}()
+
+produce:
+	for {
+		m, n := next()
+		if m == nil {
+			break
+		}
+		select {
+		case queue <- message{m: m, pixels: n}:
+		case <-stopped:
+			break produce
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if failure == nil {
+		failure = check()
+	}
+	if failure != nil {
+		return stream.Trailer(), &Error{Sent: sent, Confirmed: confirmed, Total: t.Total, Err: failure}
+	}
+	return stream.Trailer(), nil
+}
@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.1
-// 	protoc        v3.19.4
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
 // source: transform/transform.proto
 
 package transform
@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
 	sync "sync"
+	unsafe "unsafe"
 )
 
 const (
@@ -20,33 +21,1393 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Job_State int32
+
+const (
+	Job_QUEUED    Job_State = 0
+	Job_RUNNING   Job_State = 1
+	Job_DONE      Job_State = 2
+	Job_FAILED    Job_State = 3
+	Job_CANCELLED Job_State = 4
+)
+
+// Enum value maps for Job_State.
+var (
+	Job_State_name = map[int32]string{
+		0: "QUEUED",
+		1: "RUNNING",
+		2: "DONE",
+		3: "FAILED",
+		4: "CANCELLED",
+	}
+	Job_State_value = map[string]int32{
+		"QUEUED":    0,
+		"RUNNING":   1,
+		"DONE":      2,
+		"FAILED":    3,
+		"CANCELLED": 4,
+	}
+)
+
+func (x Job_State) Enum() *Job_State {
+	p := new(Job_State)
+	*p = x
+	return p
+}
+
+func (x Job_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Job_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_transform_transform_proto_enumTypes[0].Descriptor()
+}
+
+func (Job_State) Type() protoreflect.EnumType {
+	return &file_transform_transform_proto_enumTypes[0]
+}
+
+func (x Job_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Job_State.Descriptor instead.
+func (Job_State) EnumDescriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{18, 0}
+}
+
 type Pixel struct {
-	state         protoimpl.MessageState
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Color *Color                 `protobuf:"bytes,1,opt,name=color,proto3" json:"color,omitempty"`
+	Point *Point                 `protobuf:"bytes,2,opt,name=point,proto3" json:"point,omitempty"`
+	// Only read from the first message of a stream, see Pipeline.
+	Pipeline *Pipeline `protobuf:"bytes,3,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	// Only set on the last message of a stream, which holds no pixel, see
+	// Summary.
+	Summary *Summary `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	// Only set by the server on messages holding no pixel, see Progress.
+	Progress      *Progress `protobuf:"bytes,5,opt,name=progress,proto3" json:"progress,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Pixel) Reset() {
+	*x = Pixel{}
+	mi := &file_transform_transform_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pixel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pixel) ProtoMessage() {}
+
+func (x *Pixel) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pixel.ProtoReflect.Descriptor instead.
+func (*Pixel) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Pixel) GetColor() *Color {
+	if x != nil {
+		return x.Color
+	}
+	return nil
+}
+
+func (x *Pixel) GetPoint() *Point {
+	if x != nil {
+		return x.Point
+	}
+	return nil
+}
+
+func (x *Pixel) GetPipeline() *Pipeline {
+	if x != nil {
+		return x.Pipeline
+	}
+	return nil
+}
+
+func (x *Pixel) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *Pixel) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+type Point struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Point) Reset() {
+	*x = Point{}
+	mi := &file_transform_transform_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Point) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Point) ProtoMessage() {}
+
+func (x *Point) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Point.ProtoReflect.Descriptor instead.
+func (*Point) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Point) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Point) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// A rectangular block of pixels. A row is a tile with a height of 1.
+type Tile struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Top-left corner of the tile within the image.
+	Origin *Point `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Width  int32  `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32  `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	// Packed 8-bit non-premultiplied RGBA, row by row, 4 bytes per pixel.
+	Rgba []byte `protobuf:"bytes,4,opt,name=rgba,proto3" json:"rgba,omitempty"`
+	// Only read from the first message of a stream, see Pipeline.
+	Pipeline *Pipeline `protobuf:"bytes,5,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	// Only set on the last message of a stream, which holds no pixels, see
+	// Summary.
+	Summary *Summary `protobuf:"bytes,6,opt,name=summary,proto3" json:"summary,omitempty"`
+	// Only set by the server on messages holding no pixels, see Progress.
+	Progress      *Progress `protobuf:"bytes,7,opt,name=progress,proto3" json:"progress,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tile) Reset() {
+	*x = Tile{}
+	mi := &file_transform_transform_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tile) ProtoMessage() {}
+
+func (x *Tile) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tile.ProtoReflect.Descriptor instead.
+func (*Tile) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Tile) GetOrigin() *Point {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *Tile) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Tile) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Tile) GetRgba() []byte {
+	if x != nil {
+		return x.Rgba
+	}
+	return nil
+}
+
+func (x *Tile) GetPipeline() *Pipeline {
+	if x != nil {
+		return x.Pipeline
+	}
+	return nil
+}
+
+func (x *Tile) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *Tile) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+// How far the server got through a Transform or TransformTiles stream. It
+// is sent periodically to a client that asked for it with the
+// progress-interval metadata.
+type Progress struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Pixels received from the client and sent back to it so far.
+	Received int64 `protobuf:"varint,1,opt,name=received,proto3" json:"received,omitempty"`
+	Sent     int64 `protobuf:"varint,2,opt,name=sent,proto3" json:"sent,omitempty"`
+	// Pixels of the image announced in the metadata of the stream, 0 when
+	// it was not.
+	Total         int64 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_transform_transform_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Progress) GetReceived() int64 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+func (x *Progress) GetSent() int64 {
+	if x != nil {
+		return x.Sent
+	}
+	return 0
+}
+
+func (x *Progress) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+// The pixels that went through a Transform or TransformTiles stream. A
+// client may end the stream it sends with a summary of its pixels. The
+// server then checks that it received the same ones and ends the stream it
+// sends with a summary of the pixels it received and of those it sent back.
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Input         *Tally                 `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Output        *Tally                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_transform_transform_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Summary) GetInput() *Tally {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *Summary) GetOutput() *Tally {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+// A count and checksum of pixels that do not depend on their order.
+type Tally struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Pixels int64                  `protobuf:"varint,1,opt,name=pixels,proto3" json:"pixels,omitempty"`
+	// Sum, wrapping around, of the CRC-64 (ECMA) of every pixel as its x
+	// and y coordinates, 4 bytes each, and its RGBA bytes, in big-endian
+	// order. The color of a Pixel is rounded to 8-bit RGBA.
+	Checksum      uint64 `protobuf:"fixed64,2,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tally) Reset() {
+	*x = Tally{}
+	mi := &file_transform_transform_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tally) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tally) ProtoMessage() {}
+
+func (x *Tally) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tally.ProtoReflect.Descriptor instead.
+func (*Tally) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Tally) GetPixels() int64 {
+	if x != nil {
+		return x.Pixels
+	}
+	return 0
+}
+
+func (x *Tally) GetChecksum() uint64 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+// An ordered list of filters applied to an image. When set on the first
+// message of a stream it replaces the filter selected through metadata.
+type Pipeline struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Steps []*Step                `protobuf:"bytes,1,rep,name=steps,proto3" json:"steps,omitempty"`
+	// Limits the steps to part of the image, the whole image when unset.
+	Region        *Region `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Pipeline) Reset() {
+	*x = Pipeline{}
+	mi := &file_transform_transform_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pipeline) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pipeline) ProtoMessage() {}
+
+func (x *Pipeline) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pipeline.ProtoReflect.Descriptor instead.
+func (*Pipeline) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Pipeline) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *Pipeline) GetRegion() *Region {
+	if x != nil {
+		return x.Region
+	}
+	return nil
+}
+
+// A part of an image a pipeline is limited to. Pixels outside of it are sent
+// back unchanged, so a client may send only the pixels of the region. The
+// steps see the region as an image of its own and must keep its size.
+type Region struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Top-left corner of the region within the image.
+	Origin *Point `protobuf:"bytes,1,opt,name=origin,proto3" json:"origin,omitempty"`
+	Width  int32  `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32  `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	// Optional coverage of every pixel of the region, row by row, one byte
+	// per pixel: 0 keeps the source pixel, 255 takes the filtered one and
+	// values in between blend the two.
+	Mask          []byte `protobuf:"bytes,4,opt,name=mask,proto3" json:"mask,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Region) Reset() {
+	*x = Region{}
+	mi := &file_transform_transform_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Region) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Region) ProtoMessage() {}
+
+func (x *Region) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Region.ProtoReflect.Descriptor instead.
+func (*Region) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Region) GetOrigin() *Point {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *Region) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Region) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Region) GetMask() []byte {
+	if x != nil {
+		return x.Mask
+	}
+	return nil
+}
+
+// One filter of a pipeline.
+type Step struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of a filter registered on the server.
+	Filter string             `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	Params map[string]float64 `protobuf:"bytes,2,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	// Weights of a user supplied kernel, row by row.
+	Kernel []float32 `protobuf:"fixed32,3,rep,packed,name=kernel,proto3" json:"kernel,omitempty"`
+	// How pixels outside of the image are read: clamp (default), wrap or
+	// mirror.
+	Edge          string `protobuf:"bytes,4,opt,name=edge,proto3" json:"edge,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Step) Reset() {
+	*x = Step{}
+	mi := &file_transform_transform_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Step) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Step) ProtoMessage() {}
+
+func (x *Step) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Step.ProtoReflect.Descriptor instead.
+func (*Step) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Step) GetFilter() string {
+	if x != nil {
+		return x.Filter
+	}
+	return ""
+}
+
+func (x *Step) GetParams() map[string]float64 {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *Step) GetKernel() []float32 {
+	if x != nil {
+		return x.Kernel
+	}
+	return nil
+}
+
+func (x *Step) GetEdge() string {
+	if x != nil {
+		return x.Edge
+	}
+	return ""
+}
+
+// The response message containing the greetings
+type Color struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	R             float32                `protobuf:"fixed32,1,opt,name=r,proto3" json:"r,omitempty"`
+	G             float32                `protobuf:"fixed32,2,opt,name=g,proto3" json:"g,omitempty"`
+	B             float32                `protobuf:"fixed32,3,opt,name=b,proto3" json:"b,omitempty"`
+	A             float32                `protobuf:"fixed32,4,opt,name=a,proto3" json:"a,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Color) Reset() {
+	*x = Color{}
+	mi := &file_transform_transform_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Color) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Color) ProtoMessage() {}
+
+func (x *Color) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Color.ProtoReflect.Descriptor instead.
+func (*Color) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Color) GetR() float32 {
+	if x != nil {
+		return x.R
+	}
+	return 0
+}
+
+func (x *Color) GetG() float32 {
+	if x != nil {
+		return x.G
+	}
+	return 0
+}
+
+func (x *Color) GetB() float32 {
+	if x != nil {
+		return x.B
+	}
+	return 0
+}
+
+func (x *Color) GetA() float32 {
+	if x != nil {
+		return x.A
+	}
+	return 0
+}
+
+type ImageRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Content of an image file.
+	Image []byte `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	// Format of image: jpeg, png or gif. Detected from the content when empty.
+	Format string `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	// Filters applied to the image, the one selected through metadata when
+	// empty.
+	Pipeline *Pipeline `protobuf:"bytes,3,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	// Format of the result, the format of image when empty.
+	OutputFormat string `protobuf:"bytes,4,opt,name=output_format,json=outputFormat,proto3" json:"output_format,omitempty"`
+	// Quality of a JPEG result from 1 to 100, 75 when 0.
+	Quality       int32 `protobuf:"varint,5,opt,name=quality,proto3" json:"quality,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageRequest) Reset() {
+	*x = ImageRequest{}
+	mi := &file_transform_transform_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageRequest) ProtoMessage() {}
+
+func (x *ImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageRequest.ProtoReflect.Descriptor instead.
+func (*ImageRequest) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ImageRequest) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *ImageRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImageRequest) GetPipeline() *Pipeline {
+	if x != nil {
+		return x.Pipeline
+	}
+	return nil
+}
+
+func (x *ImageRequest) GetOutputFormat() string {
+	if x != nil {
+		return x.OutputFormat
+	}
+	return ""
+}
+
+func (x *ImageRequest) GetQuality() int32 {
+	if x != nil {
+		return x.Quality
+	}
+	return 0
+}
+
+// A part of an image file. Only data is read from chunks other than the
+// first one, the other fields are the same as in ImageRequest.
+type ImageChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	Pipeline      *Pipeline              `protobuf:"bytes,3,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	OutputFormat  string                 `protobuf:"bytes,4,opt,name=output_format,json=outputFormat,proto3" json:"output_format,omitempty"`
+	Quality       int32                  `protobuf:"varint,5,opt,name=quality,proto3" json:"quality,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageChunk) Reset() {
+	*x = ImageChunk{}
+	mi := &file_transform_transform_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageChunk) ProtoMessage() {}
+
+func (x *ImageChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageChunk.ProtoReflect.Descriptor instead.
+func (*ImageChunk) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ImageChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ImageChunk) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImageChunk) GetPipeline() *Pipeline {
+	if x != nil {
+		return x.Pipeline
+	}
+	return nil
+}
+
+func (x *ImageChunk) GetOutputFormat() string {
+	if x != nil {
+		return x.OutputFormat
+	}
+	return ""
+}
+
+func (x *ImageChunk) GetQuality() int32 {
+	if x != nil {
+		return x.Quality
+	}
+	return 0
+}
+
+type ImageResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Content of the transformed image file.
+	Image         []byte `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	Format        string `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	Width         int32  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageResponse) Reset() {
+	*x = ImageResponse{}
+	mi := &file_transform_transform_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageResponse) ProtoMessage() {}
+
+func (x *ImageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageResponse.ProtoReflect.Descriptor instead.
+func (*ImageResponse) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ImageResponse) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *ImageResponse) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImageResponse) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ImageResponse) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+// One message of an Analyze stream. Every message of a stream must carry the
+// same kind of input.
+type AnalyzeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Input:
+	//
+	//	*AnalyzeRequest_Pixel
+	//	*AnalyzeRequest_Tile
+	//	*AnalyzeRequest_Chunk
+	Input isAnalyzeRequest_Input `protobuf_oneof:"input"`
+	// Number of dominant colours to find, 5 when 0. Only read from the first
+	// message.
+	Colors        int32 `protobuf:"varint,4,opt,name=colors,proto3" json:"colors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_transform_transform_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AnalyzeRequest) GetInput() isAnalyzeRequest_Input {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetPixel() *Pixel {
+	if x != nil {
+		if x, ok := x.Input.(*AnalyzeRequest_Pixel); ok {
+			return x.Pixel
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetTile() *Tile {
+	if x != nil {
+		if x, ok := x.Input.(*AnalyzeRequest_Tile); ok {
+			return x.Tile
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetChunk() *ImageChunk {
+	if x != nil {
+		if x, ok := x.Input.(*AnalyzeRequest_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetColors() int32 {
+	if x != nil {
+		return x.Colors
+	}
+	return 0
+}
+
+type isAnalyzeRequest_Input interface {
+	isAnalyzeRequest_Input()
+}
+
+type AnalyzeRequest_Pixel struct {
+	Pixel *Pixel `protobuf:"bytes,1,opt,name=pixel,proto3,oneof"`
+}
+
+type AnalyzeRequest_Tile struct {
+	Tile *Tile `protobuf:"bytes,2,opt,name=tile,proto3,oneof"`
+}
+
+type AnalyzeRequest_Chunk struct {
+	// A part of an encoded image file, only the format of the first
+	// chunk is read.
+	Chunk *ImageChunk `protobuf:"bytes,3,opt,name=chunk,proto3,oneof"`
+}
+
+func (*AnalyzeRequest_Pixel) isAnalyzeRequest_Input() {}
+
+func (*AnalyzeRequest_Tile) isAnalyzeRequest_Input() {}
+
+func (*AnalyzeRequest_Chunk) isAnalyzeRequest_Input() {}
+
+type Analysis struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Size of an encoded image, 0 when the image was sent as pixels or
+	// tiles.
+	Width  int32 `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32 `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	// Number of pixels analysed.
+	Pixels int64 `protobuf:"varint,3,opt,name=pixels,proto3" json:"pixels,omitempty"`
+	// Statistics of the red, green, blue and alpha channels, in this order.
+	Channels []*ChannelStats `protobuf:"bytes,4,rep,name=channels,proto3" json:"channels,omitempty"`
+	// Statistics of the Rec. 601 luma of every pixel.
+	Luminance *ChannelStats `protobuf:"bytes,5,opt,name=luminance,proto3" json:"luminance,omitempty"`
+	// Dominant colours of the pixels that are not fully transparent, the
+	// most common first.
+	Dominant      []*DominantColor `protobuf:"bytes,6,rep,name=dominant,proto3" json:"dominant,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Analysis) Reset() {
+	*x = Analysis{}
+	mi := &file_transform_transform_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Analysis) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Analysis) ProtoMessage() {}
+
+func (x *Analysis) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Analysis.ProtoReflect.Descriptor instead.
+func (*Analysis) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Analysis) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Analysis) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Analysis) GetPixels() int64 {
+	if x != nil {
+		return x.Pixels
+	}
+	return 0
+}
+
+func (x *Analysis) GetChannels() []*ChannelStats {
+	if x != nil {
+		return x.Channels
+	}
+	return nil
+}
+
+func (x *Analysis) GetLuminance() *ChannelStats {
+	if x != nil {
+		return x.Luminance
+	}
+	return nil
+}
+
+func (x *Analysis) GetDominant() []*DominantColor {
+	if x != nil {
+		return x.Dominant
+	}
+	return nil
+}
+
+type ChannelStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Number of pixels of each 8-bit value.
+	Histogram     []int64 `protobuf:"varint,2,rep,packed,name=histogram,proto3" json:"histogram,omitempty"`
+	Min           float64 `protobuf:"fixed64,3,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64 `protobuf:"fixed64,4,opt,name=max,proto3" json:"max,omitempty"`
+	Mean          float64 `protobuf:"fixed64,5,opt,name=mean,proto3" json:"mean,omitempty"`
+	Stddev        float64 `protobuf:"fixed64,6,opt,name=stddev,proto3" json:"stddev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChannelStats) Reset() {
+	*x = ChannelStats{}
+	mi := &file_transform_transform_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChannelStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChannelStats) ProtoMessage() {}
+
+func (x *ChannelStats) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChannelStats.ProtoReflect.Descriptor instead.
+func (*ChannelStats) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChannelStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ChannelStats) GetHistogram() []int64 {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+func (x *ChannelStats) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *ChannelStats) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *ChannelStats) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *ChannelStats) GetStddev() float64 {
+	if x != nil {
+		return x.Stddev
+	}
+	return 0
+}
+
+type DominantColor struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Color *Color                 `protobuf:"bytes,1,opt,name=color,proto3" json:"color,omitempty"`
+	// Fraction of the pixels that are not fully transparent closest to
+	// color.
+	Share         float64 `protobuf:"fixed64,2,opt,name=share,proto3" json:"share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DominantColor) Reset() {
+	*x = DominantColor{}
+	mi := &file_transform_transform_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DominantColor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DominantColor) ProtoMessage() {}
+
+func (x *DominantColor) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DominantColor.ProtoReflect.Descriptor instead.
+func (*DominantColor) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DominantColor) GetColor() *Color {
+	if x != nil {
+		return x.Color
+	}
+	return nil
+}
+
+func (x *DominantColor) GetShare() float64 {
+	if x != nil {
+		return x.Share
+	}
+	return 0
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-	Color *Color `protobuf:"bytes,1,opt,name=color,proto3" json:"color,omitempty"`
-	Point *Point `protobuf:"bytes,2,opt,name=point,proto3" json:"point,omitempty"`
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_transform_transform_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *Pixel) Reset() {
-	*x = Pixel{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[0]
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[17]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
+	return mi.MessageOf(x)
 }
 
-func (x *Pixel) String() string {
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *JobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Job struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State Job_State              `protobuf:"varint,2,opt,name=state,proto3,enum=transform.Job_State" json:"state,omitempty"`
+	// Fraction of the work done, from 0 to 1.
+	Progress float64 `protobuf:"fixed64,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	// Why the job failed.
+	Error string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	// Format and size of the result of a job that is done.
+	Format string `protobuf:"bytes,5,opt,name=format,proto3" json:"format,omitempty"`
+	Width  int32  `protobuf:"varint,6,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32  `protobuf:"varint,7,opt,name=height,proto3" json:"height,omitempty"`
+	// Time the job was submitted, in seconds since the Unix epoch.
+	Created int64 `protobuf:"varint,8,opt,name=created,proto3" json:"created,omitempty"`
+	// Time the job ended, 0 while it has not.
+	Ended         int64 `protobuf:"varint,9,opt,name=ended,proto3" json:"ended,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_transform_transform_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Pixel) ProtoMessage() {}
+func (*Job) ProtoMessage() {}
 
-func (x *Pixel) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[0]
-	if protoimpl.UnsafeEnabled && x != nil {
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[18]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -56,52 +1417,192 @@ func (x *Pixel) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Pixel.ProtoReflect.Descriptor instead.
-func (*Pixel) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *Pixel) GetColor() *Color {
+func (x *Job) GetId() string {
 	if x != nil {
-		return x.Color
+		return x.Id
 	}
-	return nil
+	return ""
 }
 
-func (x *Pixel) GetPoint() *Point {
+func (x *Job) GetState() Job_State {
 	if x != nil {
-		return x.Point
+		return x.State
 	}
-	return nil
+	return Job_QUEUED
 }
 
-type Point struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+func (x *Job) GetProgress() float64 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Job) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Job) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Job) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Job) GetEnded() int64 {
+	if x != nil {
+		return x.Ended
+	}
+	return 0
+}
+
+// The first message of a Transfer stream starts or resumes a transfer, the
+// following ones carry the rows of the image, in order.
+type TransferRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*TransferRequest_Start
+	//	*TransferRequest_Rows
+	Message       isTransferRequest_Message `protobuf_oneof:"message"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-	X int32 `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
-	Y int32 `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+func (x *TransferRequest) Reset() {
+	*x = TransferRequest{}
+	mi := &file_transform_transform_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *Point) Reset() {
-	*x = Point{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[1]
+func (x *TransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferRequest) ProtoMessage() {}
+
+func (x *TransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[19]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
+	return mi.MessageOf(x)
 }
 
-func (x *Point) String() string {
+// Deprecated: Use TransferRequest.ProtoReflect.Descriptor instead.
+func (*TransferRequest) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *TransferRequest) GetMessage() isTransferRequest_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *TransferRequest) GetStart() *TransferStart {
+	if x != nil {
+		if x, ok := x.Message.(*TransferRequest_Start); ok {
+			return x.Start
+		}
+	}
+	return nil
+}
+
+func (x *TransferRequest) GetRows() *Tile {
+	if x != nil {
+		if x, ok := x.Message.(*TransferRequest_Rows); ok {
+			return x.Rows
+		}
+	}
+	return nil
+}
+
+type isTransferRequest_Message interface {
+	isTransferRequest_Message()
+}
+
+type TransferRequest_Start struct {
+	Start *TransferStart `protobuf:"bytes,1,opt,name=start,proto3,oneof"`
+}
+
+type TransferRequest_Rows struct {
+	// Whole rows of the image, starting at the row the last
+	// acknowledgement asks for.
+	Rows *Tile `protobuf:"bytes,2,opt,name=rows,proto3,oneof"`
+}
+
+func (*TransferRequest_Start) isTransferRequest_Message() {}
+
+func (*TransferRequest_Rows) isTransferRequest_Message() {}
+
+type TransferStart struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Transfer to resume, empty to start a new one.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Size of the image and filters applied, read when starting a transfer.
+	// The filter selected through metadata is applied without a pipeline.
+	Width    int32     `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height   int32     `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	Pipeline *Pipeline `protobuf:"bytes,4,opt,name=pipeline,proto3" json:"pipeline,omitempty"`
+	// Number of rows of the result the client already received, which the
+	// server does not send again.
+	Received      int32 `protobuf:"varint,5,opt,name=received,proto3" json:"received,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferStart) Reset() {
+	*x = TransferStart{}
+	mi := &file_transform_transform_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferStart) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Point) ProtoMessage() {}
+func (*TransferStart) ProtoMessage() {}
 
-func (x *Point) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[1]
-	if protoimpl.UnsafeEnabled && x != nil {
+func (x *TransferStart) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[20]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -111,55 +1612,160 @@ func (x *Point) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Point.ProtoReflect.Descriptor instead.
-func (*Point) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use TransferStart.ProtoReflect.Descriptor instead.
+func (*TransferStart) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *Point) GetX() int32 {
+func (x *TransferStart) GetId() string {
 	if x != nil {
-		return x.X
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TransferStart) GetWidth() int32 {
+	if x != nil {
+		return x.Width
 	}
 	return 0
 }
 
-func (x *Point) GetY() int32 {
+func (x *TransferStart) GetHeight() int32 {
 	if x != nil {
-		return x.Y
+		return x.Height
 	}
 	return 0
 }
 
-// The response message containing the greetings
-type Color struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+func (x *TransferStart) GetPipeline() *Pipeline {
+	if x != nil {
+		return x.Pipeline
+	}
+	return nil
+}
+
+func (x *TransferStart) GetReceived() int32 {
+	if x != nil {
+		return x.Received
+	}
+	return 0
+}
+
+// The server acknowledges every message of a Transfer stream and sends the
+// rows of the result in order, as they become ready.
+type TransferResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*TransferResponse_Ack
+	//	*TransferResponse_Rows
+	Message       isTransferResponse_Message `protobuf_oneof:"message"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-	R float32 `protobuf:"fixed32,1,opt,name=r,proto3" json:"r,omitempty"`
-	G float32 `protobuf:"fixed32,2,opt,name=g,proto3" json:"g,omitempty"`
-	B float32 `protobuf:"fixed32,3,opt,name=b,proto3" json:"b,omitempty"`
-	A float32 `protobuf:"fixed32,4,opt,name=a,proto3" json:"a,omitempty"`
+func (x *TransferResponse) Reset() {
+	*x = TransferResponse{}
+	mi := &file_transform_transform_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *Color) Reset() {
-	*x = Color{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[2]
+func (x *TransferResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferResponse) ProtoMessage() {}
+
+func (x *TransferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[21]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
+	return mi.MessageOf(x)
 }
 
-func (x *Color) String() string {
+// Deprecated: Use TransferResponse.ProtoReflect.Descriptor instead.
+func (*TransferResponse) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TransferResponse) GetMessage() isTransferResponse_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *TransferResponse) GetAck() *TransferAck {
+	if x != nil {
+		if x, ok := x.Message.(*TransferResponse_Ack); ok {
+			return x.Ack
+		}
+	}
+	return nil
+}
+
+func (x *TransferResponse) GetRows() *Tile {
+	if x != nil {
+		if x, ok := x.Message.(*TransferResponse_Rows); ok {
+			return x.Rows
+		}
+	}
+	return nil
+}
+
+type isTransferResponse_Message interface {
+	isTransferResponse_Message()
+}
+
+type TransferResponse_Ack struct {
+	Ack *TransferAck `protobuf:"bytes,1,opt,name=ack,proto3,oneof"`
+}
+
+type TransferResponse_Rows struct {
+	Rows *Tile `protobuf:"bytes,2,opt,name=rows,proto3,oneof"`
+}
+
+func (*TransferResponse_Ack) isTransferResponse_Message() {}
+
+func (*TransferResponse_Rows) isTransferResponse_Message() {}
+
+type TransferAck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the transfer when resuming it.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Number of rows of the image the server holds, from the top. A
+	// resumed transfer continues with the next one.
+	Rows int32 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+	// Size of the result.
+	Width         int32 `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32 `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferAck) Reset() {
+	*x = TransferAck{}
+	mi := &file_transform_transform_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferAck) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Color) ProtoMessage() {}
+func (*TransferAck) ProtoMessage() {}
 
-func (x *Color) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[2]
-	if protoimpl.UnsafeEnabled && x != nil {
+func (x *TransferAck) ProtoReflect() protoreflect.Message {
+	mi := &file_transform_transform_proto_msgTypes[22]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -169,54 +1775,51 @@ func (x *Color) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Color.ProtoReflect.Descriptor instead.
-func (*Color) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use TransferAck.ProtoReflect.Descriptor instead.
+func (*TransferAck) Descriptor() ([]byte, []int) {
+	return file_transform_transform_proto_rawDescGZIP(), []int{22}
 }
 
-func (x *Color) GetR() float32 {
+func (x *TransferAck) GetId() string {
 	if x != nil {
-		return x.R
+		return x.Id
 	}
-	return 0
+	return ""
 }
 
-func (x *Color) GetG() float32 {
+func (x *TransferAck) GetRows() int32 {
 	if x != nil {
-		return x.G
+		return x.Rows
 	}
 	return 0
 }
 
-func (x *Color) GetB() float32 {
+func (x *TransferAck) GetWidth() int32 {
 	if x != nil {
-		return x.B
+		return x.Width
 	}
 	return 0
 }
 
-func (x *Color) GetA() float32 {
+func (x *TransferAck) GetHeight() int32 {
 	if x != nil {
-		return x.A
+		return x.Height
 	}
 	return 0
 }
 
 type ErrorHandlingResponse struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ErrorHandlingResponse) Reset() {
 	*x = ErrorHandlingResponse{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[3]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_transform_transform_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *ErrorHandlingResponse) String() string {
@@ -226,8 +1829,8 @@ func (x *ErrorHandlingResponse) String() string {
 func (*ErrorHandlingResponse) ProtoMessage() {}
 
 func (x *ErrorHandlingResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_transform_transform_proto_msgTypes[23]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -239,7 +1842,7 @@ func (x *ErrorHandlingResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ErrorHandlingResponse.ProtoReflect.Descriptor instead.
 func (*ErrorHandlingResponse) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{3}
+	return file_transform_transform_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *ErrorHandlingResponse) GetMessage() string {
@@ -250,20 +1853,17 @@ func (x *ErrorHandlingResponse) GetMessage() string {
 }
 
 type ErrorHandlingRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ErrorHandlingRequest) Reset() {
 	*x = ErrorHandlingRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[4]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_transform_transform_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *ErrorHandlingRequest) String() string {
@@ -273,8 +1873,8 @@ func (x *ErrorHandlingRequest) String() string {
 func (*ErrorHandlingRequest) ProtoMessage() {}
 
 func (x *ErrorHandlingRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[4]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_transform_transform_proto_msgTypes[24]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -286,7 +1886,7 @@ func (x *ErrorHandlingRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ErrorHandlingRequest.ProtoReflect.Descriptor instead.
 func (*ErrorHandlingRequest) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{4}
+	return file_transform_transform_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *ErrorHandlingRequest) GetMessage() string {
@@ -297,20 +1897,17 @@ func (x *ErrorHandlingRequest) GetMessage() string {
 }
 
 type HelloRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HelloRequest) Reset() {
 	*x = HelloRequest{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[5]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_transform_transform_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *HelloRequest) String() string {
@@ -320,8 +1917,8 @@ func (x *HelloRequest) String() string {
 func (*HelloRequest) ProtoMessage() {}
 
 func (x *HelloRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[5]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_transform_transform_proto_msgTypes[25]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -333,7 +1930,7 @@ func (x *HelloRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelloRequest.ProtoReflect.Descriptor instead.
 func (*HelloRequest) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{5}
+	return file_transform_transform_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *HelloRequest) GetName() string {
@@ -344,20 +1941,17 @@ func (x *HelloRequest) GetName() string {
 }
 
 type HelloResponse struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Message string `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HelloResponse) Reset() {
 	*x = HelloResponse{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_transform_transform_proto_msgTypes[6]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
-	}
+	mi := &file_transform_transform_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
 func (x *HelloResponse) String() string {
@@ -367,8 +1961,8 @@ func (x *HelloResponse) String() string {
 func (*HelloResponse) ProtoMessage() {}
 
 func (x *HelloResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_transform_transform_proto_msgTypes[6]
-	if protoimpl.UnsafeEnabled && x != nil {
+	mi := &file_transform_transform_proto_msgTypes[26]
+	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
@@ -380,7 +1974,7 @@ func (x *HelloResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HelloResponse.ProtoReflect.Descriptor instead.
 func (*HelloResponse) Descriptor() ([]byte, []int) {
-	return file_transform_transform_proto_rawDescGZIP(), []int{6}
+	return file_transform_transform_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *HelloResponse) GetMessage() string {
@@ -392,88 +1986,268 @@ func (x *HelloResponse) GetMessage() string {
 
 var File_transform_transform_proto protoreflect.FileDescriptor
 
-var file_transform_transform_proto_rawDesc = []byte{
-	0x0a, 0x19, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2f, 0x74, 0x72, 0x61, 0x6e,
-	0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x74, 0x72, 0x61,
-	0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x22, 0x57, 0x0a, 0x05, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x12,
-	0x26, 0x0a, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
-	0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x43, 0x6f, 0x6c, 0x6f, 0x72,
-	0x52, 0x05, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x12, 0x26, 0x0a, 0x05, 0x70, 0x6f, 0x69, 0x6e, 0x74,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f,
-	0x72, 0x6d, 0x2e, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x52, 0x05, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x22,
-	0x23, 0x0a, 0x05, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x12, 0x0c, 0x0a, 0x01, 0x78, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x05, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x05, 0x52, 0x01, 0x79, 0x22, 0x3f, 0x0a, 0x05, 0x43, 0x6f, 0x6c, 0x6f, 0x72, 0x12, 0x0c, 0x0a,
-	0x01, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x01, 0x72, 0x12, 0x0c, 0x0a, 0x01, 0x67,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x01, 0x67, 0x12, 0x0c, 0x0a, 0x01, 0x62, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x02, 0x52, 0x01, 0x62, 0x12, 0x0c, 0x0a, 0x01, 0x61, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x02, 0x52, 0x01, 0x61, 0x22, 0x31, 0x0a, 0x15, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x48, 0x61,
-	0x6e, 0x64, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18,
-	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x30, 0x0a, 0x14, 0x45, 0x72, 0x72, 0x6f,
-	0x72, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x22, 0x0a, 0x0c, 0x48, 0x65,
-	0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
-	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0x29,
-	0x0a, 0x0d, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
-	0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x32, 0xd9, 0x01, 0x0a, 0x09, 0x54, 0x72,
-	0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x35, 0x0a, 0x09, 0x54, 0x72, 0x61, 0x6e, 0x73,
-	0x66, 0x6f, 0x72, 0x6d, 0x12, 0x10, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d,
-	0x2e, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x1a, 0x10, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f,
-	0x72, 0x6d, 0x2e, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x54,
-	0x0a, 0x0d, 0x53, 0x69, 0x6d, 0x75, 0x6c, 0x61, 0x74, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12,
-	0x1f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x45, 0x72, 0x72, 0x6f,
-	0x72, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x1a, 0x20, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x45, 0x72, 0x72,
-	0x6f, 0x72, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x22, 0x00, 0x12, 0x3f, 0x0a, 0x08, 0x53, 0x61, 0x79, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
-	0x12, 0x17, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x48, 0x65, 0x6c,
-	0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x74, 0x72, 0x61, 0x6e,
-	0x73, 0x66, 0x6f, 0x72, 0x6d, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x22, 0x5a, 0x20, 0x6e, 0x69, 0x63, 0x68, 0x6f, 0x77, 0x69,
-	0x6c, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2d, 0x74, 0x75, 0x74, 0x6f, 0x72, 0x69, 0x61, 0x6c, 0x2f,
-	0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x6f, 0x72, 0x6d, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x33,
-}
+const file_transform_transform_proto_rawDesc = "" +
+	"\n" +
+	"\x19transform/transform.proto\x12\ttransform\"\xe7\x01\n" +
+	"\x05Pixel\x12&\n" +
+	"\x05color\x18\x01 \x01(\v2\x10.transform.ColorR\x05color\x12&\n" +
+	"\x05point\x18\x02 \x01(\v2\x10.transform.PointR\x05point\x12/\n" +
+	"\bpipeline\x18\x03 \x01(\v2\x13.transform.PipelineR\bpipeline\x12,\n" +
+	"\asummary\x18\x04 \x01(\v2\x12.transform.SummaryR\asummary\x12/\n" +
+	"\bprogress\x18\x05 \x01(\v2\x13.transform.ProgressR\bprogress\"#\n" +
+	"\x05Point\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\"\x82\x02\n" +
+	"\x04Tile\x12(\n" +
+	"\x06origin\x18\x01 \x01(\v2\x10.transform.PointR\x06origin\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\x12\x12\n" +
+	"\x04rgba\x18\x04 \x01(\fR\x04rgba\x12/\n" +
+	"\bpipeline\x18\x05 \x01(\v2\x13.transform.PipelineR\bpipeline\x12,\n" +
+	"\asummary\x18\x06 \x01(\v2\x12.transform.SummaryR\asummary\x12/\n" +
+	"\bprogress\x18\a \x01(\v2\x13.transform.ProgressR\bprogress\"P\n" +
+	"\bProgress\x12\x1a\n" +
+	"\breceived\x18\x01 \x01(\x03R\breceived\x12\x12\n" +
+	"\x04sent\x18\x02 \x01(\x03R\x04sent\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x03R\x05total\"[\n" +
+	"\aSummary\x12&\n" +
+	"\x05input\x18\x01 \x01(\v2\x10.transform.TallyR\x05input\x12(\n" +
+	"\x06output\x18\x02 \x01(\v2\x10.transform.TallyR\x06output\";\n" +
+	"\x05Tally\x12\x16\n" +
+	"\x06pixels\x18\x01 \x01(\x03R\x06pixels\x12\x1a\n" +
+	"\bchecksum\x18\x02 \x01(\x06R\bchecksum\"\\\n" +
+	"\bPipeline\x12%\n" +
+	"\x05steps\x18\x01 \x03(\v2\x0f.transform.StepR\x05steps\x12)\n" +
+	"\x06region\x18\x02 \x01(\v2\x11.transform.RegionR\x06region\"t\n" +
+	"\x06Region\x12(\n" +
+	"\x06origin\x18\x01 \x01(\v2\x10.transform.PointR\x06origin\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\x12\x12\n" +
+	"\x04mask\x18\x04 \x01(\fR\x04mask\"\xba\x01\n" +
+	"\x04Step\x12\x16\n" +
+	"\x06filter\x18\x01 \x01(\tR\x06filter\x123\n" +
+	"\x06params\x18\x02 \x03(\v2\x1b.transform.Step.ParamsEntryR\x06params\x12\x16\n" +
+	"\x06kernel\x18\x03 \x03(\x02R\x06kernel\x12\x12\n" +
+	"\x04edge\x18\x04 \x01(\tR\x04edge\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"?\n" +
+	"\x05Color\x12\f\n" +
+	"\x01r\x18\x01 \x01(\x02R\x01r\x12\f\n" +
+	"\x01g\x18\x02 \x01(\x02R\x01g\x12\f\n" +
+	"\x01b\x18\x03 \x01(\x02R\x01b\x12\f\n" +
+	"\x01a\x18\x04 \x01(\x02R\x01a\"\xac\x01\n" +
+	"\fImageRequest\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\fR\x05image\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12/\n" +
+	"\bpipeline\x18\x03 \x01(\v2\x13.transform.PipelineR\bpipeline\x12#\n" +
+	"\routput_format\x18\x04 \x01(\tR\foutputFormat\x12\x18\n" +
+	"\aquality\x18\x05 \x01(\x05R\aquality\"\xa8\x01\n" +
+	"\n" +
+	"ImageChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12/\n" +
+	"\bpipeline\x18\x03 \x01(\v2\x13.transform.PipelineR\bpipeline\x12#\n" +
+	"\routput_format\x18\x04 \x01(\tR\foutputFormat\x12\x18\n" +
+	"\aquality\x18\x05 \x01(\x05R\aquality\"k\n" +
+	"\rImageResponse\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\fR\x05image\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"\xb1\x01\n" +
+	"\x0eAnalyzeRequest\x12(\n" +
+	"\x05pixel\x18\x01 \x01(\v2\x10.transform.PixelH\x00R\x05pixel\x12%\n" +
+	"\x04tile\x18\x02 \x01(\v2\x0f.transform.TileH\x00R\x04tile\x12-\n" +
+	"\x05chunk\x18\x03 \x01(\v2\x15.transform.ImageChunkH\x00R\x05chunk\x12\x16\n" +
+	"\x06colors\x18\x04 \x01(\x05R\x06colorsB\a\n" +
+	"\x05input\"\xf2\x01\n" +
+	"\bAnalysis\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x16\n" +
+	"\x06pixels\x18\x03 \x01(\x03R\x06pixels\x123\n" +
+	"\bchannels\x18\x04 \x03(\v2\x17.transform.ChannelStatsR\bchannels\x125\n" +
+	"\tluminance\x18\x05 \x01(\v2\x17.transform.ChannelStatsR\tluminance\x124\n" +
+	"\bdominant\x18\x06 \x03(\v2\x18.transform.DominantColorR\bdominant\"\x90\x01\n" +
+	"\fChannelStats\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
+	"\thistogram\x18\x02 \x03(\x03R\thistogram\x12\x10\n" +
+	"\x03min\x18\x03 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\x04 \x01(\x01R\x03max\x12\x12\n" +
+	"\x04mean\x18\x05 \x01(\x01R\x04mean\x12\x16\n" +
+	"\x06stddev\x18\x06 \x01(\x01R\x06stddev\"M\n" +
+	"\rDominantColor\x12&\n" +
+	"\x05color\x18\x01 \x01(\v2\x10.transform.ColorR\x05color\x12\x14\n" +
+	"\x05share\x18\x02 \x01(\x01R\x05share\"\x1c\n" +
+	"\n" +
+	"JobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb0\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x05state\x18\x02 \x01(\x0e2\x14.transform.Job.StateR\x05state\x12\x1a\n" +
+	"\bprogress\x18\x03 \x01(\x01R\bprogress\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x16\n" +
+	"\x06format\x18\x05 \x01(\tR\x06format\x12\x14\n" +
+	"\x05width\x18\x06 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\a \x01(\x05R\x06height\x12\x18\n" +
+	"\acreated\x18\b \x01(\x03R\acreated\x12\x14\n" +
+	"\x05ended\x18\t \x01(\x03R\x05ended\"E\n" +
+	"\x05State\x12\n" +
+	"\n" +
+	"\x06QUEUED\x10\x00\x12\v\n" +
+	"\aRUNNING\x10\x01\x12\b\n" +
+	"\x04DONE\x10\x02\x12\n" +
+	"\n" +
+	"\x06FAILED\x10\x03\x12\r\n" +
+	"\tCANCELLED\x10\x04\"u\n" +
+	"\x0fTransferRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x18.transform.TransferStartH\x00R\x05start\x12%\n" +
+	"\x04rows\x18\x02 \x01(\v2\x0f.transform.TileH\x00R\x04rowsB\t\n" +
+	"\amessage\"\x9a\x01\n" +
+	"\rTransferStart\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\x12/\n" +
+	"\bpipeline\x18\x04 \x01(\v2\x13.transform.PipelineR\bpipeline\x12\x1a\n" +
+	"\breceived\x18\x05 \x01(\x05R\breceived\"p\n" +
+	"\x10TransferResponse\x12*\n" +
+	"\x03ack\x18\x01 \x01(\v2\x16.transform.TransferAckH\x00R\x03ack\x12%\n" +
+	"\x04rows\x18\x02 \x01(\v2\x0f.transform.TileH\x00R\x04rowsB\t\n" +
+	"\amessage\"_\n" +
+	"\vTransferAck\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04rows\x18\x02 \x01(\x05R\x04rows\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"1\n" +
+	"\x15ErrorHandlingResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"0\n" +
+	"\x14ErrorHandlingRequest\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"\"\n" +
+	"\fHelloRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\")\n" +
+	"\rHelloResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage2\xc1\x06\n" +
+	"\tTransform\x125\n" +
+	"\tTransform\x12\x10.transform.Pixel\x1a\x10.transform.Pixel\"\x00(\x010\x01\x128\n" +
+	"\x0eTransformTiles\x12\x0f.transform.Tile\x1a\x0f.transform.Tile\"\x00(\x010\x01\x12E\n" +
+	"\x0eTransformImage\x12\x17.transform.ImageRequest\x1a\x18.transform.ImageResponse\"\x00\x12B\n" +
+	"\vUploadImage\x12\x15.transform.ImageChunk\x1a\x18.transform.ImageResponse\"\x00(\x01\x12=\n" +
+	"\aAnalyze\x12\x19.transform.AnalyzeRequest\x1a\x13.transform.Analysis\"\x00(\x01\x126\n" +
+	"\tSubmitJob\x12\x15.transform.ImageChunk\x1a\x0e.transform.Job\"\x00(\x01\x121\n" +
+	"\x06GetJob\x12\x15.transform.JobRequest\x1a\x0e.transform.Job\"\x00\x125\n" +
+	"\bWatchJob\x12\x15.transform.JobRequest\x1a\x0e.transform.Job\"\x000\x01\x124\n" +
+	"\tCancelJob\x12\x15.transform.JobRequest\x1a\x0e.transform.Job\"\x00\x12?\n" +
+	"\vFetchResult\x12\x15.transform.JobRequest\x1a\x15.transform.ImageChunk\"\x000\x01\x12I\n" +
+	"\bTransfer\x12\x1a.transform.TransferRequest\x1a\x1b.transform.TransferResponse\"\x00(\x010\x01\x12T\n" +
+	"\rSimulateError\x12\x1f.transform.ErrorHandlingRequest\x1a .transform.ErrorHandlingResponse\"\x00\x12?\n" +
+	"\bSayHello\x12\x17.transform.HelloRequest\x1a\x18.transform.HelloResponse\"\x00B\"Z nichowil/grpc-tutorial/transformb\x06proto3"
 
 var (
 	file_transform_transform_proto_rawDescOnce sync.Once
-	file_transform_transform_proto_rawDescData = file_transform_transform_proto_rawDesc
+	file_transform_transform_proto_rawDescData []byte
 )
 
 func file_transform_transform_proto_rawDescGZIP() []byte {
 	file_transform_transform_proto_rawDescOnce.Do(func() {
-		file_transform_transform_proto_rawDescData = protoimpl.X.CompressGZIP(file_transform_transform_proto_rawDescData)
+		file_transform_transform_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transform_transform_proto_rawDesc), len(file_transform_transform_proto_rawDesc)))
 	})
 	return file_transform_transform_proto_rawDescData
 }
 
-var file_transform_transform_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
-var file_transform_transform_proto_goTypes = []interface{}{
-	(*Pixel)(nil),                 // 0: transform.Pixel
-	(*Point)(nil),                 // 1: transform.Point
-	(*Color)(nil),                 // 2: transform.Color
-	(*ErrorHandlingResponse)(nil), // 3: transform.ErrorHandlingResponse
-	(*ErrorHandlingRequest)(nil),  // 4: transform.ErrorHandlingRequest
-	(*HelloRequest)(nil),          // 5: transform.HelloRequest
-	(*HelloResponse)(nil),         // 6: transform.HelloResponse
+var file_transform_transform_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_transform_transform_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
+var file_transform_transform_proto_goTypes = []any{
+	(Job_State)(0),                // 0: transform.Job.State
+	(*Pixel)(nil),                 // 1: transform.Pixel
+	(*Point)(nil),                 // 2: transform.Point
+	(*Tile)(nil),                  // 3: transform.Tile
+	(*Progress)(nil),              // 4: transform.Progress
+	(*Summary)(nil),               // 5: transform.Summary
+	(*Tally)(nil),                 // 6: transform.Tally
+	(*Pipeline)(nil),              // 7: transform.Pipeline
+	(*Region)(nil),                // 8: transform.Region
+	(*Step)(nil),                  // 9: transform.Step
+	(*Color)(nil),                 // 10: transform.Color
+	(*ImageRequest)(nil),          // 11: transform.ImageRequest
+	(*ImageChunk)(nil),            // 12: transform.ImageChunk
+	(*ImageResponse)(nil),         // 13: transform.ImageResponse
+	(*AnalyzeRequest)(nil),        // 14: transform.AnalyzeRequest
+	(*Analysis)(nil),              // 15: transform.Analysis
+	(*ChannelStats)(nil),          // 16: transform.ChannelStats
+	(*DominantColor)(nil),         // 17: transform.DominantColor
+	(*JobRequest)(nil),            // 18: transform.JobRequest
+	(*Job)(nil),                   // 19: transform.Job
+	(*TransferRequest)(nil),       // 20: transform.TransferRequest
+	(*TransferStart)(nil),         // 21: transform.TransferStart
+	(*TransferResponse)(nil),      // 22: transform.TransferResponse
+	(*TransferAck)(nil),           // 23: transform.TransferAck
+	(*ErrorHandlingResponse)(nil), // 24: transform.ErrorHandlingResponse
+	(*ErrorHandlingRequest)(nil),  // 25: transform.ErrorHandlingRequest
+	(*HelloRequest)(nil),          // 26: transform.HelloRequest
+	(*HelloResponse)(nil),         // 27: transform.HelloResponse
+	nil,                           // 28: transform.Step.ParamsEntry
 }
 var file_transform_transform_proto_depIdxs = []int32{
-	2, // 0: transform.Pixel.color:type_name -> transform.Color
-	1, // 1: transform.Pixel.point:type_name -> transform.Point
-	0, // 2: transform.Transform.Transform:input_type -> transform.Pixel
-	4, // 3: transform.Transform.SimulateError:input_type -> transform.ErrorHandlingRequest
-	5, // 4: transform.Transform.SayHello:input_type -> transform.HelloRequest
-	0, // 5: transform.Transform.Transform:output_type -> transform.Pixel
-	3, // 6: transform.Transform.SimulateError:output_type -> transform.ErrorHandlingResponse
-	6, // 7: transform.Transform.SayHello:output_type -> transform.HelloResponse
-	5, // [5:8] is the sub-list for method output_type
-	2, // [2:5] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	10, // 0: transform.Pixel.color:type_name -> transform.Color
+	2,  // 1: transform.Pixel.point:type_name -> transform.Point
+	7,  // 2: transform.Pixel.pipeline:type_name -> transform.Pipeline
+	5,  // 3: transform.Pixel.summary:type_name -> transform.Summary
+	4,  // 4: transform.Pixel.progress:type_name -> transform.Progress
+	2,  // 5: transform.Tile.origin:type_name -> transform.Point
+	7,  // 6: transform.Tile.pipeline:type_name -> transform.Pipeline
+	5,  // 7: transform.Tile.summary:type_name -> transform.Summary
+	4,  // 8: transform.Tile.progress:type_name -> transform.Progress
+	6,  // 9: transform.Summary.input:type_name -> transform.Tally
+	6,  // 10: transform.Summary.output:type_name -> transform.Tally
+	9,  // 11: transform.Pipeline.steps:type_name -> transform.Step
+	8,  // 12: transform.Pipeline.region:type_name -> transform.Region
+	2,  // 13: transform.Region.origin:type_name -> transform.Point
+	28, // 14: transform.Step.params:type_name -> transform.Step.ParamsEntry
+	7,  // 15: transform.ImageRequest.pipeline:type_name -> transform.Pipeline
+	7,  // 16: transform.ImageChunk.pipeline:type_name -> transform.Pipeline
+	1,  // 17: transform.AnalyzeRequest.pixel:type_name -> transform.Pixel
+	3,  // 18: transform.AnalyzeRequest.tile:type_name -> transform.Tile
+	12, // 19: transform.AnalyzeRequest.chunk:type_name -> transform.ImageChunk
+	16, // 20: transform.Analysis.channels:type_name -> transform.ChannelStats
+	16, // 21: transform.Analysis.luminance:type_name -> transform.ChannelStats
+	17, // 22: transform.Analysis.dominant:type_name -> transform.DominantColor
+	10, // 23: transform.DominantColor.color:type_name -> transform.Color
+	0,  // 24: transform.Job.state:type_name -> transform.Job.State
+	21, // 25: transform.TransferRequest.start:type_name -> transform.TransferStart
+	3,  // 26: transform.TransferRequest.rows:type_name -> transform.Tile
+	7,  // 27: transform.TransferStart.pipeline:type_name -> transform.Pipeline
+	23, // 28: transform.TransferResponse.ack:type_name -> transform.TransferAck
+	3,  // 29: transform.TransferResponse.rows:type_name -> transform.Tile
+	1,  // 30: transform.Transform.Transform:input_type -> transform.Pixel
+	3,  // 31: transform.Transform.TransformTiles:input_type -> transform.Tile
+	11, // 32: transform.Transform.TransformImage:input_type -> transform.ImageRequest
+	12, // 33: transform.Transform.UploadImage:input_type -> transform.ImageChunk
+	14, // 34: transform.Transform.Analyze:input_type -> transform.AnalyzeRequest
+	12, // 35: transform.Transform.SubmitJob:input_type -> transform.ImageChunk
+	18, // 36: transform.Transform.GetJob:input_type -> transform.JobRequest
+	18, // 37: transform.Transform.WatchJob:input_type -> transform.JobRequest
+	18, // 38: transform.Transform.CancelJob:input_type -> transform.JobRequest
+	18, // 39: transform.Transform.FetchResult:input_type -> transform.JobRequest
+	20, // 40: transform.Transform.Transfer:input_type -> transform.TransferRequest
+	25, // 41: transform.Transform.SimulateError:input_type -> transform.ErrorHandlingRequest
+	26, // 42: transform.Transform.SayHello:input_type -> transform.HelloRequest
+	1,  // 43: transform.Transform.Transform:output_type -> transform.Pixel
+	3,  // 44: transform.Transform.TransformTiles:output_type -> transform.Tile
+	13, // 45: transform.Transform.TransformImage:output_type -> transform.ImageResponse
+	13, // 46: transform.Transform.UploadImage:output_type -> transform.ImageResponse
+	15, // 47: transform.Transform.Analyze:output_type -> transform.Analysis
+	19, // 48: transform.Transform.SubmitJob:output_type -> transform.Job
+	19, // 49: transform.Transform.GetJob:output_type -> transform.Job
+	19, // 50: transform.Transform.WatchJob:output_type -> transform.Job
+	19, // 51: transform.Transform.CancelJob:output_type -> transform.Job
+	12, // 52: transform.Transform.FetchResult:output_type -> transform.ImageChunk
+	22, // 53: transform.Transform.Transfer:output_type -> transform.TransferResponse
+	24, // 54: transform.Transform.SimulateError:output_type -> transform.ErrorHandlingResponse
+	27, // 55: transform.Transform.SayHello:output_type -> transform.HelloResponse
+	43, // [43:56] is the sub-list for method output_type
+	30, // [30:43] is the sub-list for method input_type
+	30, // [30:30] is the sub-list for extension type_name
+	30, // [30:30] is the sub-list for extension extendee
+	0,  // [0:30] is the sub-list for field type_name
 }
 
 func init() { file_transform_transform_proto_init() }
@@ -481,108 +2255,35 @@ func file_transform_transform_proto_init() {
 	if File_transform_transform_proto != nil {
 		return
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_transform_transform_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Pixel); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Point); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Color); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ErrorHandlingResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ErrorHandlingRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HelloRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_transform_transform_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HelloResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
+	file_transform_transform_proto_msgTypes[13].OneofWrappers = []any{
+		(*AnalyzeRequest_Pixel)(nil),
+		(*AnalyzeRequest_Tile)(nil),
+		(*AnalyzeRequest_Chunk)(nil),
+	}
+	file_transform_transform_proto_msgTypes[19].OneofWrappers = []any{
+		(*TransferRequest_Start)(nil),
+		(*TransferRequest_Rows)(nil),
+	}
+	file_transform_transform_proto_msgTypes[21].OneofWrappers = []any{
+		(*TransferResponse_Ack)(nil),
+		(*TransferResponse_Rows)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_transform_transform_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   7,
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transform_transform_proto_rawDesc), len(file_transform_transform_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   28,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_transform_transform_proto_goTypes,
 		DependencyIndexes: file_transform_transform_proto_depIdxs,
+		EnumInfos:         file_transform_transform_proto_enumTypes,
 		MessageInfos:      file_transform_transform_proto_msgTypes,
 	}.Build()
 	File_transform_transform_proto = out.File
-	file_transform_transform_proto_rawDesc = nil
 	file_transform_transform_proto_goTypes = nil
 	file_transform_transform_proto_depIdxs = nil
 }
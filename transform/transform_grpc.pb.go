@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
-// - protoc-gen-go-grpc v1.2.0
-// - protoc             v3.19.4
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
 // source: transform/transform.proto
 
 package transform
@@ -15,15 +15,56 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.32.0 or later.
-const _ = grpc.SupportPackageIsVersion7
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Transform_Transform_FullMethodName      = "/transform.Transform/Transform"
+	Transform_TransformTiles_FullMethodName = "/transform.Transform/TransformTiles"
+	Transform_TransformImage_FullMethodName = "/transform.Transform/TransformImage"
+	Transform_UploadImage_FullMethodName    = "/transform.Transform/UploadImage"
+	Transform_Analyze_FullMethodName        = "/transform.Transform/Analyze"
+	Transform_SubmitJob_FullMethodName      = "/transform.Transform/SubmitJob"
+	Transform_GetJob_FullMethodName         = "/transform.Transform/GetJob"
+	Transform_WatchJob_FullMethodName       = "/transform.Transform/WatchJob"
+	Transform_CancelJob_FullMethodName      = "/transform.Transform/CancelJob"
+	Transform_FetchResult_FullMethodName    = "/transform.Transform/FetchResult"
+	Transform_Transfer_FullMethodName       = "/transform.Transform/Transfer"
+	Transform_SimulateError_FullMethodName  = "/transform.Transform/SimulateError"
+	Transform_SayHello_FullMethodName       = "/transform.Transform/SayHello"
+)
 
 // TransformClient is the client API for Transform service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Service definition.
 type TransformClient interface {
 	// Transforms image
-	Transform(ctx context.Context, opts ...grpc.CallOption) (Transform_TransformClient, error)
+	Transform(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Pixel, Pixel], error)
+	// Transforms image a row or tile at a time
+	TransformTiles(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Tile, Tile], error)
+	// Transforms an encoded image file, decoded and encoded again by the server
+	TransformImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*ImageResponse, error)
+	// Transforms an encoded image file uploaded in chunks
+	UploadImage(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, ImageResponse], error)
+	// Computes statistics of an image sent as pixels, tiles or an encoded file
+	Analyze(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AnalyzeRequest, Analysis], error)
+	// Stores an encoded image file uploaded in chunks and transforms it in
+	// the background, independently of the connection of the client
+	SubmitJob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, Job], error)
+	// Reports the state of a job
+	GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	// Streams the state of a job every time it changes, until it ends
+	WatchJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error)
+	// Aborts a job that has not ended yet
+	CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	// Streams the transformed image file of a job that is done, in chunks
+	FetchResult(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ImageChunk], error)
+	// Transforms an image sent row by row over a transfer that outlives the
+	// stream: a client whose connection dropped resumes it on a new stream
+	// from the last rows acknowledged
+	Transfer(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TransferRequest, TransferResponse], error)
 	SimulateError(ctx context.Context, in *ErrorHandlingRequest, opts ...grpc.CallOption) (*ErrorHandlingResponse, error)
 	SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
 }
@@ -36,40 +77,156 @@ func NewTransformClient(cc grpc.ClientConnInterface) TransformClient {
 	return &transformClient{cc}
 }
 
-func (c *transformClient) Transform(ctx context.Context, opts ...grpc.CallOption) (Transform_TransformClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[0], "/transform.Transform/Transform", opts...)
+func (c *transformClient) Transform(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Pixel, Pixel], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[0], Transform_Transform_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &transformTransformClient{stream}
+	x := &grpc.GenericClientStream[Pixel, Pixel]{ClientStream: stream}
 	return x, nil
 }
 
-type Transform_TransformClient interface {
-	Send(*Pixel) error
-	Recv() (*Pixel, error)
-	grpc.ClientStream
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransformClient = grpc.BidiStreamingClient[Pixel, Pixel]
+
+func (c *transformClient) TransformTiles(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Tile, Tile], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[1], Transform_TransformTiles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Tile, Tile]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransformTilesClient = grpc.BidiStreamingClient[Tile, Tile]
+
+func (c *transformClient) TransformImage(ctx context.Context, in *ImageRequest, opts ...grpc.CallOption) (*ImageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImageResponse)
+	err := c.cc.Invoke(ctx, Transform_TransformImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transformClient) UploadImage(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, ImageResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[2], Transform_UploadImage_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImageChunk, ImageResponse]{ClientStream: stream}
+	return x, nil
 }
 
-type transformTransformClient struct {
-	grpc.ClientStream
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_UploadImageClient = grpc.ClientStreamingClient[ImageChunk, ImageResponse]
+
+func (c *transformClient) Analyze(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[AnalyzeRequest, Analysis], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[3], Transform_Analyze_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnalyzeRequest, Analysis]{ClientStream: stream}
+	return x, nil
 }
 
-func (x *transformTransformClient) Send(m *Pixel) error {
-	return x.ClientStream.SendMsg(m)
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_AnalyzeClient = grpc.ClientStreamingClient[AnalyzeRequest, Analysis]
+
+func (c *transformClient) SubmitJob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, Job], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[4], Transform_SubmitJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImageChunk, Job]{ClientStream: stream}
+	return x, nil
 }
 
-func (x *transformTransformClient) Recv() (*Pixel, error) {
-	m := new(Pixel)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_SubmitJobClient = grpc.ClientStreamingClient[ImageChunk, Job]
+
+func (c *transformClient) GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Transform_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
 		return nil, err
 	}
-	return m, nil
+	return out, nil
 }
 
+func (c *transformClient) WatchJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[5], Transform_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JobRequest, Job]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_WatchJobClient = grpc.ServerStreamingClient[Job]
+
+func (c *transformClient) CancelJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Transform_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transformClient) FetchResult(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ImageChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[6], Transform_FetchResult_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JobRequest, ImageChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_FetchResultClient = grpc.ServerStreamingClient[ImageChunk]
+
+func (c *transformClient) Transfer(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[TransferRequest, TransferResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transform_ServiceDesc.Streams[7], Transform_Transfer_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TransferRequest, TransferResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransferClient = grpc.BidiStreamingClient[TransferRequest, TransferResponse]
+
 func (c *transformClient) SimulateError(ctx context.Context, in *ErrorHandlingRequest, opts ...grpc.CallOption) (*ErrorHandlingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ErrorHandlingResponse)
-	err := c.cc.Invoke(ctx, "/transform.Transform/SimulateError", in, out, opts...)
+	err := c.cc.Invoke(ctx, Transform_SimulateError_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +234,9 @@ func (c *transformClient) SimulateError(ctx context.Context, in *ErrorHandlingRe
 }
 
 func (c *transformClient) SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(HelloResponse)
-	err := c.cc.Invoke(ctx, "/transform.Transform/SayHello", in, out, opts...)
+	err := c.cc.Invoke(ctx, Transform_SayHello_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -87,29 +245,88 @@ func (c *transformClient) SayHello(ctx context.Context, in *HelloRequest, opts .
 
 // TransformServer is the server API for Transform service.
 // All implementations must embed UnimplementedTransformServer
-// for forward compatibility
+// for forward compatibility.
+//
+// Service definition.
 type TransformServer interface {
 	// Transforms image
-	Transform(Transform_TransformServer) error
+	Transform(grpc.BidiStreamingServer[Pixel, Pixel]) error
+	// Transforms image a row or tile at a time
+	TransformTiles(grpc.BidiStreamingServer[Tile, Tile]) error
+	// Transforms an encoded image file, decoded and encoded again by the server
+	TransformImage(context.Context, *ImageRequest) (*ImageResponse, error)
+	// Transforms an encoded image file uploaded in chunks
+	UploadImage(grpc.ClientStreamingServer[ImageChunk, ImageResponse]) error
+	// Computes statistics of an image sent as pixels, tiles or an encoded file
+	Analyze(grpc.ClientStreamingServer[AnalyzeRequest, Analysis]) error
+	// Stores an encoded image file uploaded in chunks and transforms it in
+	// the background, independently of the connection of the client
+	SubmitJob(grpc.ClientStreamingServer[ImageChunk, Job]) error
+	// Reports the state of a job
+	GetJob(context.Context, *JobRequest) (*Job, error)
+	// Streams the state of a job every time it changes, until it ends
+	WatchJob(*JobRequest, grpc.ServerStreamingServer[Job]) error
+	// Aborts a job that has not ended yet
+	CancelJob(context.Context, *JobRequest) (*Job, error)
+	// Streams the transformed image file of a job that is done, in chunks
+	FetchResult(*JobRequest, grpc.ServerStreamingServer[ImageChunk]) error
+	// Transforms an image sent row by row over a transfer that outlives the
+	// stream: a client whose connection dropped resumes it on a new stream
+	// from the last rows acknowledged
+	Transfer(grpc.BidiStreamingServer[TransferRequest, TransferResponse]) error
 	SimulateError(context.Context, *ErrorHandlingRequest) (*ErrorHandlingResponse, error)
 	SayHello(context.Context, *HelloRequest) (*HelloResponse, error)
 	mustEmbedUnimplementedTransformServer()
 }
 
-// UnimplementedTransformServer must be embedded to have forward compatible implementations.
-type UnimplementedTransformServer struct {
-}
+// UnimplementedTransformServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTransformServer struct{}
 
-func (UnimplementedTransformServer) Transform(Transform_TransformServer) error {
-	return status.Errorf(codes.Unimplemented, "method Transform not implemented")
+func (UnimplementedTransformServer) Transform(grpc.BidiStreamingServer[Pixel, Pixel]) error {
+	return status.Error(codes.Unimplemented, "method Transform not implemented")
+}
+func (UnimplementedTransformServer) TransformTiles(grpc.BidiStreamingServer[Tile, Tile]) error {
+	return status.Error(codes.Unimplemented, "method TransformTiles not implemented")
+}
+func (UnimplementedTransformServer) TransformImage(context.Context, *ImageRequest) (*ImageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method TransformImage not implemented")
+}
+func (UnimplementedTransformServer) UploadImage(grpc.ClientStreamingServer[ImageChunk, ImageResponse]) error {
+	return status.Error(codes.Unimplemented, "method UploadImage not implemented")
+}
+func (UnimplementedTransformServer) Analyze(grpc.ClientStreamingServer[AnalyzeRequest, Analysis]) error {
+	return status.Error(codes.Unimplemented, "method Analyze not implemented")
+}
+func (UnimplementedTransformServer) SubmitJob(grpc.ClientStreamingServer[ImageChunk, Job]) error {
+	return status.Error(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedTransformServer) GetJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedTransformServer) WatchJob(*JobRequest, grpc.ServerStreamingServer[Job]) error {
+	return status.Error(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedTransformServer) CancelJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedTransformServer) FetchResult(*JobRequest, grpc.ServerStreamingServer[ImageChunk]) error {
+	return status.Error(codes.Unimplemented, "method FetchResult not implemented")
+}
+func (UnimplementedTransformServer) Transfer(grpc.BidiStreamingServer[TransferRequest, TransferResponse]) error {
+	return status.Error(codes.Unimplemented, "method Transfer not implemented")
 }
 func (UnimplementedTransformServer) SimulateError(context.Context, *ErrorHandlingRequest) (*ErrorHandlingResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SimulateError not implemented")
+	return nil, status.Error(codes.Unimplemented, "method SimulateError not implemented")
 }
 func (UnimplementedTransformServer) SayHello(context.Context, *HelloRequest) (*HelloResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SayHello not implemented")
+	return nil, status.Error(codes.Unimplemented, "method SayHello not implemented")
 }
 func (UnimplementedTransformServer) mustEmbedUnimplementedTransformServer() {}
+func (UnimplementedTransformServer) testEmbeddedByValue()                   {}
 
 // UnsafeTransformServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to TransformServer will
@@ -119,35 +336,134 @@ type UnsafeTransformServer interface {
 }
 
 func RegisterTransformServer(s grpc.ServiceRegistrar, srv TransformServer) {
+	// If the following call panics, it indicates UnimplementedTransformServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
 	s.RegisterService(&Transform_ServiceDesc, srv)
 }
 
 func _Transform_Transform_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(TransformServer).Transform(&transformTransformServer{stream})
+	return srv.(TransformServer).Transform(&grpc.GenericServerStream[Pixel, Pixel]{ServerStream: stream})
 }
 
-type Transform_TransformServer interface {
-	Send(*Pixel) error
-	Recv() (*Pixel, error)
-	grpc.ServerStream
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransformServer = grpc.BidiStreamingServer[Pixel, Pixel]
+
+func _Transform_TransformTiles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransformServer).TransformTiles(&grpc.GenericServerStream[Tile, Tile]{ServerStream: stream})
 }
 
-type transformTransformServer struct {
-	grpc.ServerStream
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransformTilesServer = grpc.BidiStreamingServer[Tile, Tile]
+
+func _Transform_TransformImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransformServer).TransformImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transform_TransformImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransformServer).TransformImage(ctx, req.(*ImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transform_UploadImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransformServer).UploadImage(&grpc.GenericServerStream[ImageChunk, ImageResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_UploadImageServer = grpc.ClientStreamingServer[ImageChunk, ImageResponse]
+
+func _Transform_Analyze_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransformServer).Analyze(&grpc.GenericServerStream[AnalyzeRequest, Analysis]{ServerStream: stream})
 }
 
-func (x *transformTransformServer) Send(m *Pixel) error {
-	return x.ServerStream.SendMsg(m)
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_AnalyzeServer = grpc.ClientStreamingServer[AnalyzeRequest, Analysis]
+
+func _Transform_SubmitJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransformServer).SubmitJob(&grpc.GenericServerStream[ImageChunk, Job]{ServerStream: stream})
 }
 
-func (x *transformTransformServer) Recv() (*Pixel, error) {
-	m := new(Pixel)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_SubmitJobServer = grpc.ClientStreamingServer[ImageChunk, Job]
+
+func _Transform_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return m, nil
+	if interceptor == nil {
+		return srv.(TransformServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transform_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransformServer).GetJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transform_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransformServer).WatchJob(m, &grpc.GenericServerStream[JobRequest, Job]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_WatchJobServer = grpc.ServerStreamingServer[Job]
+
+func _Transform_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransformServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transform_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransformServer).CancelJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transform_FetchResult_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransformServer).FetchResult(m, &grpc.GenericServerStream[JobRequest, ImageChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_FetchResultServer = grpc.ServerStreamingServer[ImageChunk]
+
+func _Transform_Transfer_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TransformServer).Transfer(&grpc.GenericServerStream[TransferRequest, TransferResponse]{ServerStream: stream})
 }
 
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transform_TransferServer = grpc.BidiStreamingServer[TransferRequest, TransferResponse]
+
 func _Transform_SimulateError_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ErrorHandlingRequest)
 	if err := dec(in); err != nil {
@@ -158,7 +474,7 @@ func _Transform_SimulateError_Handler(srv interface{}, ctx context.Context, dec
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/transform.Transform/SimulateError",
+		FullMethod: Transform_SimulateError_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		return srv.(TransformServer).SimulateError(ctx, req.(*ErrorHandlingRequest))
@@ -176,7 +492,7 @@ func _Transform_SayHello_Handler(srv interface{}, ctx context.Context, dec func(
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/transform.Transform/SayHello",
+		FullMethod: Transform_SayHello_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		return srv.(TransformServer).SayHello(ctx, req.(*HelloRequest))
@@ -191,6 +507,18 @@ var Transform_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "transform.Transform",
 	HandlerType: (*TransformServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "TransformImage",
+			Handler:    _Transform_TransformImage_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Transform_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _Transform_CancelJob_Handler,
+		},
 		{
 			MethodName: "SimulateError",
 			Handler:    _Transform_SimulateError_Handler,
@@ -207,6 +535,43 @@ var Transform_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "TransformTiles",
+			Handler:       _Transform_TransformTiles_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UploadImage",
+			Handler:       _Transform_UploadImage_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Analyze",
+			Handler:       _Transform_Analyze_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SubmitJob",
+			Handler:       _Transform_SubmitJob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchJob",
+			Handler:       _Transform_WatchJob_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "FetchResult",
+			Handler:       _Transform_FetchResult_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Transfer",
+			Handler:       _Transform_Transfer_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "transform/transform.proto",
 }
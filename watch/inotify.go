@@ -0,0 +1,137 @@
+//go:build linux
+// +build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+
+type inotify struct {
+	w    *Watcher
+	fd   int
+	f    *os.File
+	root string
+	dirs map[int32]string // watched directories by watch descriptor
+}
+
+func start(w *Watcher, dir string) (closer, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// a non-blocking descriptor is read through the runtime poller, so
+	// closing it ends a pending read
+	in := &inotify{w: w, fd: fd, f: os.NewFile(uintptr(fd), "inotify"), root: dir, dirs: make(map[int32]string)}
+	if err := in.add(dir); err != nil {
+		in.f.Close()
+		return nil, err
+	}
+	go in.run()
+	return in, nil
+}
+
+func (in *inotify) close() error {
+	return in.f.Close()
+}
+
+func (in *inotify) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(in.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	in.dirs[int32(wd)] = dir
+	return nil
+}
+
+// addTree watches dir and the directories below it, reporting the files in
+// them when report is set. It returns false once the watcher is closed.
+func (in *inotify) addTree(dir string, report bool) bool {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			err = in.add(path)
+		}
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed since
+				return nil
+			}
+			if !in.w.error(err) {
+				return errClosed
+			}
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if report && !info.IsDir() && !in.w.file(path) {
+			return errClosed
+		}
+		return nil
+	})
+	return err != errClosed
+}
+
+func (in *inotify) run() {
+	defer close(in.w.Errors)
+	defer close(in.w.Files)
+	if !in.addTree(in.root, false) {
+		return
+	}
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.f.Read(buf)
+		if err != nil {
+			select {
+			case <-in.w.done:
+			default:
+				in.w.error(err)
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameAt := off + syscall.SizeofInotifyEvent
+			off = nameAt + int(ev.Len)
+			name := strings.TrimRight(string(buf[nameAt:off]), "\x00")
+			if !in.event(ev.Wd, ev.Mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// event handles an event of the directory wd about its entry name. It
+// returns false once the watcher is closed.
+func (in *inotify) event(wd int32, mask uint32, name string) bool {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// events were lost, any file may have been written
+		return in.addTree(in.root, true)
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(in.dirs, wd)
+		return true
+	}
+	dir, ok := in.dirs[wd]
+	if !ok || name == "" {
+		return true
+	}
+	path := filepath.Join(dir, name)
+	if mask&syscall.IN_ISDIR != 0 {
+		if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+			// files may have been written in it before it was watched
+			return in.addTree(path, true)
+		}
+		return true
+	}
+	if mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0 {
+		return in.w.file(path)
+	}
+	return true
+}
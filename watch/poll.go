@@ -0,0 +1,94 @@
+//go:build !linux
+// +build !linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often the tree is walked.
+const pollInterval = time.Second
+
+type fileState struct {
+	size     int64
+	modTime  time.Time
+	reported bool
+}
+
+type poller struct {
+	w     *Watcher
+	root  string
+	files map[string]*fileState
+}
+
+func start(w *Watcher, dir string) (closer, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	p := &poller{w: w, root: dir, files: make(map[string]*fileState)}
+	go p.run()
+	return p, nil
+}
+
+func (p *poller) close() error {
+	return nil
+}
+
+func (p *poller) run() {
+	defer close(p.w.Errors)
+	defer close(p.w.Files)
+	// files already there are not reported
+	if !p.scan(true) {
+		return
+	}
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if !p.scan(false) {
+				return
+			}
+		case <-p.w.done:
+			return
+		}
+	}
+}
+
+// scan walks the tree and reports the files that did not change since the
+// previous scan, once per change. It returns false once the watcher is
+// closed.
+func (p *poller) scan(initial bool) bool {
+	err := filepath.Walk(p.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if !p.w.error(err) {
+				return errClosed
+			}
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		st, ok := p.files[path]
+		switch {
+		case !ok || st.size != info.Size() || !st.modTime.Equal(info.ModTime()):
+			p.files[path] = &fileState{size: info.Size(), modTime: info.ModTime(), reported: initial}
+		case !st.reported:
+			st.reported = true
+			if !p.w.file(path) {
+				return errClosed
+			}
+		}
+		return nil
+	})
+	return err != errClosed
+}
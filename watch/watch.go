@@ -0,0 +1,80 @@
+// Package watch reports the files written in a directory tree. On Linux it
+// relies on inotify and reports a file once it was closed after a write or
+// moved into the tree; elsewhere the tree is polled and a file is reported
+// once its size and modification time stopped changing.
+package watch
+
+import (
+	"errors"
+	"sync"
+)
+
+// errClosed stops walking the tree once the watcher is closed.
+var errClosed = errors.New("watcher closed")
+
+// Watcher watches a directory tree, including the directories created in
+// it later.
+type Watcher struct {
+	// Files receives the path of every file written, joined to the
+	// directory watched. A file may be reported more than once.
+	Files chan string
+	// Errors receives the errors met while watching. Both channels must
+	// be read, and both are closed once the watcher stopped, after Close
+	// or an error it cannot recover from.
+	Errors chan error
+
+	done chan struct{}
+	once sync.Once
+	impl closer
+}
+
+type closer interface {
+	close() error
+}
+
+// New starts watching dir. Files already in it are not reported.
+func New(dir string) (*Watcher, error) {
+	w := &Watcher{
+		Files:  make(chan string),
+		Errors: make(chan error),
+		done:   make(chan struct{}),
+	}
+	impl, err := start(w, dir)
+	if err != nil {
+		return nil, err
+	}
+	w.impl = impl
+	return w, nil
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.impl.close()
+	})
+	return err
+}
+
+// file reports path, unless the watcher is closed. It returns false once
+// it is.
+func (w *Watcher) file(path string) bool {
+	select {
+	case w.Files <- path:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// error reports err, unless the watcher is closed. It returns false once it
+// is.
+func (w *Watcher) error(err error) bool {
+	select {
+	case w.Errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}